@@ -4,48 +4,132 @@ import (
 	"batchRequestsRecover/internal/model"
 	"batchRequestsRecover/internal/service"
 	"batchRequestsRecover/internal/util"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 // osExit is a variable to allow mocking in tests
 var osExit = os.Exit
 
+// Exit codes returned by the tool
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitInterrupted = 130
+)
+
 func Run() {
+	if code := run(); code != exitOK {
+		osExit(code)
+	}
+}
+
+func run() int {
 	args := checkAndParseArgs()
 
 	config := loadConfig(args.ConfigFilePath)
 
+	// The first SIGINT/SIGTERM stops dispatching new rows and lets in-flight
+	// requests finish; a second one falls back to the default behaviour and kills the process
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-signalCtx.Done()
+		stop()
+	}()
+
+	ctx := signalCtx
+	if deadline := config.Timeouts.RunDeadlineMillis; deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(deadline)*time.Millisecond)
+		defer cancel()
+	}
+
+	// The client is built up front so invalid TLS settings stop the run before any row is sent
+	client, err := service.NewHttpClient(*config)
+	if err != nil {
+		fmt.Println("Error configuring HTTP client:", err)
+		return exitError
+	}
+
+	checkpoint, err := service.OpenCheckpoint(args.CSVFilePath+service.CheckpointSuffix, args.Resume)
+	if err != nil {
+		fmt.Println("Error opening checkpoint:", err)
+		return exitError
+	}
+	defer checkpoint.Close()
+
 	parserService := service.NewParserService(*config)
-	processService := service.NewProcessService(*config, *args)
+	processService := service.NewProcessService(*config, *args,
+		service.WithCheckpoint(checkpoint), service.WithHttpClient(client))
 
 	fmt.Printf("Processing inputFile: %s\n", args.CSVFilePath)
+	if args.Resume {
+		fmt.Printf("Resuming, %d rows already completed\n", checkpoint.Completed())
+	}
 
-	// Parse CSV records
-	records, err := parserService.ReadAndParse(args.CSVFilePath)
+	writerOpts := []service.FileResponseWriterOption{service.WithExtractColumns(config.ExtractColumns())}
+	if !util.IsUTF8(config.InputEncoding) {
+		// the failed rows are written decoded, the BOM keeps a follow-up run from decoding them again
+		writerOpts = append(writerOpts, service.WithFailedBOM())
+	}
+	if !parserService.HasRawRows(args.CSVFilePath) {
+		writerOpts = append(writerOpts, service.WithoutFailedRows())
+	}
+	writer, err := service.NewFileResponseWriter(args.CSVFilePath, config.Output, checkpoint, args.Resume, writerOpts...)
 	if err != nil {
-		fmt.Println("Error reading CSV:", err)
-		return
+		fmt.Println("Error opening output files:", err)
+		return exitError
 	}
+	defer func() {
+		if err := writer.Close(); err != nil {
+			fmt.Println("Error closing output files:", err)
+		}
+	}()
 
-	respList, errList, err := processService.ProcessAll(records)
+	// The failed rows and out files repeat the header so they line up with the input
+	header, err := parserService.Header(args.CSVFilePath)
 	if err != nil {
-		fmt.Println("Error processing records:", err)
-		return
+		fmt.Println("Error reading header:", err)
+		return exitError
+	}
+	if err := writer.WriteHeader(header); err != nil {
+		fmt.Println("Error writing failed rows file:", err)
+		return exitError
 	}
 
-	util.WriteResponses(args.CSVFilePath, errList, ".err")
-	util.WriteResponses(args.CSVFilePath, respList, ".resp")
+	// Records are parsed lazily, one row at a time, as the workers pull them
+	records := parserService.Records(args.CSVFilePath)
+	err = processService.ProcessAll(ctx, records, writer)
+	if errors.Is(err, service.ErrInterrupted) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			fmt.Println("Run deadline reached")
+		}
+		fmt.Println("Run interrupted, partial results saved. Run again with -resume to continue")
+		return exitInterrupted
+	}
+	if err != nil {
+		fmt.Println("Error processing records:", err)
+		return exitError
+	}
 
+	return exitOK
 }
 
 func checkAndParseArgs() *model.CommandLineArgs {
 	csvFilePath := flag.String("inputFile", "", "Path to CSV inputFile")
 	configFilePath := flag.String("configPath", "config.json", "Path to config file, default is config.json")
 	dryRun := flag.Bool("dry", true, "Dry run")
-	sleep := flag.Int("sleep", 1000, "Sleep milli seconds between requests")
+	sleep := flag.Int("sleep", 1000, "Sleep milli seconds between requests, used when no rate_limit is configured")
+	resume := flag.Bool("resume", false, "Resume a previous run, skipping the rows listed in the checkpoint")
+	concurrency := flag.Int("concurrency", 0, "Number of parallel requests, overrides the config value")
 
 	flag.Parse()
 
@@ -58,6 +142,8 @@ func checkAndParseArgs() *model.CommandLineArgs {
 		ConfigFilePath: *configFilePath,
 		DryRun:         *dryRun,
 		SleepMillis:    *sleep,
+		Concurrency:    *concurrency,
+		Resume:         *resume,
 	}
 }
 
@@ -227,7 +227,7 @@ func TestCheckAndParseArgs_CustomValues(t *testing.T) {
 	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 
 	// Set args with custom values
-	os.Args = []string{"cmd", "-inputFile=data.tsv", "-configPath=custom.json", "-dry=false", "-sleep=5"}
+	os.Args = []string{"cmd", "-inputFile=data.tsv", "-configPath=custom.json", "-dry=false", "-sleep=5", "-concurrency=4", "-resume"}
 
 	args := checkAndParseArgs()
 
@@ -243,6 +243,12 @@ func TestCheckAndParseArgs_CustomValues(t *testing.T) {
 	if args.SleepMillis != 5 {
 		t.Errorf("SleepMillis = %v, want 5", args.SleepMillis)
 	}
+	if args.Concurrency != 4 {
+		t.Errorf("Concurrency = %v, want 4", args.Concurrency)
+	}
+	if !args.Resume {
+		t.Errorf("Resume = %v, want true", args.Resume)
+	}
 }
 
 func TestCheckAndParseArgs_EdgeCases(t *testing.T) {
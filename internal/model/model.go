@@ -3,9 +3,15 @@ package model
 import (
 	"batchRequestsRecover/internal/util"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
 	"strings"
+	"time"
 )
 
 // Config represents the structure for configuration related to API calls.
@@ -14,18 +20,249 @@ import (
 // Headers contains the key-value pairs of HTTP headers.
 // PathVars holds the dynamic segments for the URL path.
 // QueryVars represents the query parameters in the request URL.
+// Their values are escaped for the part of the URL they end up in, unless
+// PreEncoded says the input file already holds URL-encoded values.
 // HasBody indicates whether the request includes a payload body.
+// HeaderFields maps request headers to the columns holding their values,
+// which are looked up by name and so need a header row or JSON Lines input.
+// BodyTemplate, when set, builds the body from the row instead: it is a Go
+// text/template rendered with the named row values (see RowValues), which
+// ApiEndpoint and the Headers values may use as well.
+// Concurrency sets how many requests are sent in parallel (default 1).
+// RateLimit throttles the requests across all workers.
+// Retry defines how failed requests are retried per record.
+// RetryAfter controls how 429/503 responses with a Retry-After header are honoured.
+// Output controls how often the output files are flushed to disk and
+// whether a JSON Lines result log is written.
+// Timeouts bounds the connection phases, each request and the whole run.
+// Transport tunes the connection pool of the HTTP client shared by all workers.
+// TLS configures certificate verification and client certificates.
+// Success lists the rules a response must match to be a success (default:
+// any 2xx status) and Skip the rules that mark it as skipped instead;
+// responses matching neither are errors.
+// Assertions and ResponseSchema are checked on successful responses, and
+// turn them into errors when they fail.
+// Extract lists the values taken from successful responses and written,
+// after the input row, to the enriched output file.
+// Steps, when set, replaces the single request of a row with a chain of
+// requests sent in order; the row's result is the one of the last step, or
+// of the first step that does not succeed. Assertions, ResponseSchema and
+// Extract only apply to the response of the last step.
+// ShutdownTimeoutMillis is how long in-flight requests may take to complete
+// after an interrupt (default 10 seconds).
 // The order in the csv file is important.
 // The first n columns are the PathVars, the next n columns are the QueryVars,
 // and the last column is the body, if the request has a body (hasBody = true).
+// When HasHeader is true the first row is a header instead, and the columns
+// are looked up by name: PathVars and QueryVars by their own names and the
+// body by BodyColumn (the last header column when empty).
+// InputEncoding is the character encoding of csv and jsonl files without a
+// byte order mark: utf-8 (default), utf-16, utf-16le, utf-16be, latin1 or
+// windows-1252.
+// InputFormat is "csv", "jsonl", "xlsx", "har" or "curl", by default the one of the
+// file extension. Sheet selects the sheet of a workbook by name or by 1-based
+// position (default: the first one).
+// Every line of a JSON Lines file is an object whose fields are looked up by
+// name like header columns, nested fields as "parent.child"; the body field
+// is BodyColumn (default "body") and an object there is sent as JSON.
+// HAR input replays the recorded requests of the entries selected by HAR,
+// with the Headers set on top of the recorded ones. Curl input does the same
+// with the requests of a list of curl commands.
 type Config struct {
-	ApiEndpoint  string            `json:"api_endpoint"`
-	Method       string            `json:"method"`
-	Headers      map[string]string `json:"headers"`
-	PathVars     []string          `json:"path_vars"`
-	QueryVars    []string          `json:"query_vars"`
-	HasBody      bool              `json:"has_body"`
-	CSVDelimiter string            `json:"csv_delimiter"`
+	ApiEndpoint   string            `json:"api_endpoint"`
+	Method        string            `json:"method"`
+	Headers       map[string]string `json:"headers"`
+	PathVars      []string          `json:"path_vars"`
+	QueryVars     []string          `json:"query_vars"`
+	PreEncoded    bool              `json:"pre_encoded"`
+	HasBody       bool              `json:"has_body"`
+	BodyTemplate  string            `json:"body_template"`
+	HeaderFields  map[string]string `json:"header_fields"`
+	CSVDelimiter  string            `json:"csv_delimiter"`
+	HasHeader     bool              `json:"has_header"`
+	BodyColumn    string            `json:"body_column"`
+	InputFormat   string            `json:"input_format"`
+	InputEncoding string            `json:"input_encoding"`
+	Sheet         string            `json:"sheet"`
+	HAR           HARFilter         `json:"har"`
+	Concurrency   int               `json:"concurrency"`
+	RateLimit     RateLimit         `json:"rate_limit"`
+	Retry         RetryPolicy       `json:"retry"`
+	RetryAfter    RetryAfter        `json:"retry_after"`
+	Output        Output            `json:"output"`
+	Timeouts      Timeouts          `json:"timeouts"`
+	Transport     Transport         `json:"transport"`
+	TLS           TLS               `json:"tls"`
+	Success       []ResponseRule    `json:"success"`
+	Skip          []ResponseRule    `json:"skip"`
+
+	Assertions     []Assertion  `json:"assertions"`
+	ResponseSchema string       `json:"response_schema"`
+	Extract        []Extraction `json:"extract"`
+	Steps          []Step       `json:"steps"`
+
+	ShutdownTimeoutMillis int `json:"shutdown_timeout_ms"`
+
+	// columns maps header names to column indexes and bodyIndex locates
+	// the body column, both set by BindHeader
+	columns   map[string]int
+	bodyIndex int
+}
+
+// RateLimit describes the throughput allowed by the target API.
+// RequestsPerSecond is the sustained rate and Burst how many requests
+// can go out at once; PerMinute is an optional additional quota.
+// When nothing is set, the -sleep interval is used instead.
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+	PerMinute         int     `json:"per_minute"`
+}
+
+// RetryPolicy describes how a record is retried after a transport error
+// or a retryable status code. MaxAttempts includes the first attempt.
+// The backoff doubles at every attempt starting from BaseBackoffMillis, up to
+// MaxBackoffMillis, and Jitter (0-1) randomly shortens it by up to that fraction.
+// RetryOnStatus defaults to 502, 503 and 504; RetryOnErrors lists the error
+// kinds to retry (timeout, connection, dns, tls, other) and defaults to all.
+type RetryPolicy struct {
+	MaxAttempts       int      `json:"max_attempts"`
+	BaseBackoffMillis int      `json:"base_backoff_ms"`
+	MaxBackoffMillis  int      `json:"max_backoff_ms"`
+	Jitter            float64  `json:"jitter"`
+	RetryOnStatus     []int    `json:"retry_on_status"`
+	RetryOnErrors     []string `json:"retry_on_errors"`
+}
+
+// RetryAfter describes how 429 and 503 responses carrying a Retry-After
+// header are handled. The record is re-sent after the requested wait,
+// capped at MaxWaitMillis (default 5 minutes), at most MaxAttempts times
+// (default 5). Scope "worker" (default) pauses only the affected worker,
+// "global" pauses the whole run. Disabled turns the behaviour off.
+type RetryAfter struct {
+	Disabled      bool   `json:"disabled"`
+	Scope         string `json:"scope"`
+	MaxWaitMillis int    `json:"max_wait_ms"`
+	MaxAttempts   int    `json:"max_attempts"`
+}
+
+// Output describes how responses are streamed to the output files.
+// FlushEvery is the number of responses buffered before they are written
+// out (default 1, every response); Fsync additionally syncs the files to
+// stable storage at every flush. JSONL adds a JSON Lines log with the full
+// request and response of every row, in which the values of RedactHeaders
+// are masked (default: the usual credential headers).
+type Output struct {
+	FlushEvery    int      `json:"flush_every"`
+	Fsync         bool     `json:"fsync"`
+	JSONL         bool     `json:"jsonl"`
+	RedactHeaders []string `json:"redact_headers"`
+}
+
+// Timeouts are expressed in milliseconds, zero means no timeout.
+// ConnectMillis bounds establishing the TCP connection, TLSHandshakeMillis
+// the TLS handshake and ResponseHeaderMillis the wait for the response
+// headers once the request is sent. RequestMillis bounds each attempt as a
+// whole, body included. RunDeadlineMillis bounds the whole run: once reached
+// no new row is sent and the run stops as if interrupted.
+type Timeouts struct {
+	ConnectMillis        int `json:"connect_ms"`
+	TLSHandshakeMillis   int `json:"tls_handshake_ms"`
+	ResponseHeaderMillis int `json:"response_header_ms"`
+	RequestMillis        int `json:"request_ms"`
+	RunDeadlineMillis    int `json:"run_deadline_ms"`
+}
+
+// Transport configures the connection pool of the HTTP client, which is
+// built once per run and shared by all workers. MaxIdleConnsPerHost is the
+// number of idle connections kept per host (default 100) and
+// IdleTimeoutMillis how long they are kept (default 90 seconds).
+// DisableHTTP2 restricts HTTPS requests to HTTP/1.1 and DisableKeepAlives
+// opens a new connection for every request.
+type Transport struct {
+	MaxIdleConnsPerHost int  `json:"max_idle_conns_per_host"`
+	IdleTimeoutMillis   int  `json:"idle_timeout_ms"`
+	DisableHTTP2        bool `json:"disable_http2"`
+	DisableKeepAlives   bool `json:"disable_keep_alives"`
+}
+
+// TLS configures the HTTPS connections. CAFile and CADir add PEM encoded
+// certificate authorities to the system pool, CertFile and KeyFile hold the
+// client certificate for mutual TLS and ServerName overrides the name the
+// server certificate is verified against. MinVersion is one of "1.0",
+// "1.1", "1.2" or "1.3" (default "1.2"). InsecureSkipVerify disables
+// certificate verification and should only be used against test servers.
+type TLS struct {
+	CAFile             string `json:"ca_file"`
+	CADir              string `json:"ca_dir"`
+	CertFile           string `json:"cert_file"`
+	KeyFile            string `json:"key_file"`
+	ServerName         string `json:"server_name"`
+	MinVersion         string `json:"min_version"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+// ResponseRule matches a response when all the conditions it sets hold.
+// Status entries are single codes ("409"), ranges ("200-299") or classes
+// ("2xx"). BodyContains and BodyRegex test the raw body. JSONPath selects a
+// value of a JSON body, which must equal JSONEquals when set or just exist
+// otherwise.
+type ResponseRule struct {
+	Status       []string        `json:"status"`
+	BodyContains string          `json:"body_contains"`
+	BodyRegex    string          `json:"body_regex"`
+	JSONPath     string          `json:"json_path"`
+	JSONEquals   json.RawMessage `json:"json_equals"`
+}
+
+// Assertion checks the body of a successful response. Without JSONPath it
+// applies to the whole body, which must match Regex. With JSONPath it
+// applies to the selected value of a JSON body, which must exist and,
+// when set, equal Equals, differ from NotEquals and match Regex (strings
+// are matched as they are, other values in their JSON form). When only
+// NotEquals is set the value may also be absent. Name labels the assertion
+// in the error message.
+type Assertion struct {
+	Name      string          `json:"name"`
+	JSONPath  string          `json:"json_path"`
+	Equals    json.RawMessage `json:"equals"`
+	NotEquals json.RawMessage `json:"not_equals"`
+	Regex     string          `json:"regex"`
+}
+
+// Extraction fills the output column Column from a single source: the
+// value at JSONPath in a JSON body, the response header Header, or the
+// first match of Regex in the body (its first group when it has one).
+// Values that cannot be found are left empty.
+type Extraction struct {
+	Column   string `json:"column"`
+	JSONPath string `json:"json_path"`
+	Header   string `json:"header"`
+	Regex    string `json:"regex"`
+}
+
+// HARFilter selects the entries of a HAR input to replay: those whose URL
+// matches the URLPattern regular expression, whose method is one of Methods
+// and whose original response status is one of Status ("500", "500-599" or
+// "5xx", "0" for requests that got no response). Empty fields match all.
+type HARFilter struct {
+	URLPattern string   `json:"url_pattern"`
+	Methods    []string `json:"methods"`
+	Status     []string `json:"status"`
+}
+
+// Step is one request of a chain. ApiEndpoint, the Headers values and Body
+// may be Go templates, rendered with the row values (see Config.RowValues)
+// and, under the name of each earlier step, the values taken by its
+// Extract: {{.step.column}}. Method defaults to the Method of the config,
+// and the config Headers are sent unless overridden.
+type Step struct {
+	Name        string            `json:"name"`
+	ApiEndpoint string            `json:"api_endpoint"`
+	Method      string            `json:"method"`
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body"`
+	Extract     []Extraction      `json:"extract"`
 }
 
 type CommandLineArgs struct {
@@ -33,6 +270,21 @@ type CommandLineArgs struct {
 	ConfigFilePath string
 	DryRun         bool
 	SleepMillis    int
+	Concurrency    int
+	Resume         bool
+}
+
+// Record is a request built from one row of the input file.
+// Index is the position of the row among the non-empty rows and Raw the
+// row exactly as it appears in the file, line terminator included.
+// When the config has Steps, Request is nil and Values holds the named
+// row values the steps are built from.
+type Record struct {
+	Index   int
+	Request *http.Request
+	Row     []string
+	Raw     []byte
+	Values  map[string]string
 }
 
 type CsvRequest struct {
@@ -41,9 +293,29 @@ type CsvRequest struct {
 	Headers    map[string]string
 	Body       io.Reader
 }
+
+// Response is the outcome of a row. Message is the line written to the
+// .resp/.err files and Row and Raw the input row it was built from. The
+// other fields describe the final attempt for the result log: Latency is
+// the duration of that attempt and Error the transport error text when no
+// response was received, or the failed assertion. Extracted holds the
+// values of the extract columns, in config order.
 type Response struct {
-	Type    ResponseType
-	Message string
+	Type     ResponseType
+	Message  string
+	Status   int
+	Attempts int
+
+	Row            []string
+	Raw            []byte
+	Method         string
+	URL            string
+	RequestHeaders http.Header
+	Headers        http.Header
+	Body           []byte
+	Latency        time.Duration
+	Error          string
+	Extracted      []string
 }
 
 type ResponseType int
@@ -51,8 +323,21 @@ type ResponseType int
 const (
 	SUCCESS ResponseType = iota
 	ERROR
+	SKIPPED
 )
 
+func (t ResponseType) String() string {
+	switch t {
+	case SUCCESS:
+		return "SUCCESS"
+	case ERROR:
+		return "ERROR"
+	case SKIPPED:
+		return "SKIPPED"
+	}
+	return fmt.Sprintf("ResponseType(%d)", int(t))
+}
+
 type CsvRequestOption func(*CsvRequest)
 
 // NewCsvRequest creates a new CsvRequest with the given options
@@ -108,19 +393,121 @@ func (conf *Config) GetTotalColumns() int {
 	return totalColumns
 }
 
+// ExtractColumns returns the names of the columns extracted from the responses.
+func (conf *Config) ExtractColumns() []string {
+	names := make([]string, len(conf.Extract))
+	for i, extraction := range conf.Extract {
+		names[i] = extraction.Column
+	}
+	return names
+}
+
+// RowValues names the values of the row: every header column when the
+// header is bound, otherwise the PathVars and then the QueryVars by
+// position, and the body as BodyColumn (default "body") when HasBody is set.
+func (conf *Config) RowValues(row []string) map[string]string {
+	values := make(map[string]string, len(row))
+	if conf.columns != nil {
+		for name, index := range conf.columns {
+			if index < len(row) {
+				values[name] = util.TrimQuotes(row[index])
+			}
+		}
+		return values
+	}
+
+	for i, name := range slices.Concat(conf.PathVars, conf.QueryVars) {
+		if i < len(row) {
+			values[name] = util.TrimQuotes(row[i])
+		}
+	}
+	if conf.HasBody && len(row) > 0 {
+		bodyName := conf.BodyColumn
+		if bodyName == "" {
+			bodyName = "body"
+		}
+		values[bodyName] = row[len(row)-1]
+	}
+	return values
+}
+
+// BindHeader maps the columns used by the config to their position in the
+// header row. It fails listing every required column the header lacks.
+func (conf *Config) BindHeader(header []string) error {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = util.TrimQuotes(name)
+		if _, exists := columns[name]; !exists {
+			columns[name] = i
+		}
+	}
+
+	required := append(append([]string{}, conf.PathVars...), conf.QueryVars...)
+	if conf.HasBody && conf.BodyColumn != "" {
+		required = append(required, conf.BodyColumn)
+	}
+	for _, name := range slices.Sorted(maps.Keys(conf.HeaderFields)) {
+		required = append(required, conf.HeaderFields[name])
+	}
+
+	var missing []string
+	for _, name := range required {
+		if _, ok := columns[util.TrimQuotes(name)]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing columns in the header: %s", strings.Join(missing, ", "))
+	}
+
+	// Without an explicit body column the body is the last one, as in files without header
+	bodyIndex := len(header) - 1
+	if conf.BodyColumn != "" {
+		bodyIndex = columns[util.TrimQuotes(conf.BodyColumn)]
+	}
+
+	conf.columns = columns
+	conf.bodyIndex = bodyIndex
+	return nil
+}
+
+// columnValue returns the value for a variable, looked up by name when a
+// header is bound and by position otherwise.
+func (conf *Config) columnValue(row []string, position int, name string) (string, error) {
+	index := position
+	if conf.columns != nil {
+		index = conf.columns[util.TrimQuotes(name)]
+	}
+	if index >= len(row) {
+		return "", fmt.Errorf("not enough columns in the csv")
+	}
+	return util.TrimQuotes(row[index]), nil
+}
+
 func (conf *Config) WithPathVars(row []string) (string, error) {
-	urlRequest := conf.ApiEndpoint
+	return conf.ReplacePathVars(conf.ApiEndpoint, row)
+}
+
+// ReplacePathVars replaces the path variables of endpoint with the row
+// values, escaped as path segments, or as query values for the variables
+// placed in the query string of the endpoint.
+func (conf *Config) ReplacePathVars(endpoint string, row []string) (string, error) {
+	urlRequest := endpoint
 	if len(conf.PathVars) == 0 {
 		return urlRequest, nil
 	}
-	if len(row) < len(conf.PathVars) {
+	if conf.columns == nil && len(row) < len(conf.PathVars) {
 		return "", fmt.Errorf("not enough columns in the csv")
 	}
 
 	columnsToProcess := min(len(conf.PathVars), conf.GetTotalColumns())
 
 	for j := 0; j < columnsToProcess; j++ {
-		urlRequest = strings.Replace(urlRequest, "{"+conf.PathVars[j]+"}", util.TrimQuotes(row[j]), -1)
+		value, err := conf.columnValue(row, j, conf.PathVars[j])
+		if err != nil {
+			return "", err
+		}
+		urlRequest = conf.replaceURLVar(urlRequest, "{"+conf.PathVars[j]+"}", value)
 	}
 
 	if len(conf.PathVars) > conf.GetTotalColumns() {
@@ -129,12 +516,43 @@ func (conf *Config) WithPathVars(row []string) (string, error) {
 	return urlRequest, nil
 }
 
+// replaceURLVar replaces every occurrence of placeholder in rawURL with value.
+func (conf *Config) replaceURLVar(rawURL, placeholder, value string) string {
+	var replaced strings.Builder
+	for {
+		before, after, found := strings.Cut(rawURL, placeholder)
+		if !found {
+			replaced.WriteString(rawURL)
+			return replaced.String()
+		}
+		replaced.WriteString(before)
+		replaced.WriteString(conf.EscapeURLValue(replaced.String(), value))
+		rawURL = after
+	}
+}
+
+// EscapeURLValue escapes a value written in a URL after prefix: as a query
+// value when prefix already holds the "?" of the query string, as a path
+// segment otherwise. Values are left as they are when PreEncoded is set.
+func (conf *Config) EscapeURLValue(prefix, value string) string {
+	switch {
+	case conf.PreEncoded:
+		return value
+	case strings.Contains(prefix, "?"):
+		return url.QueryEscape(value)
+	default:
+		return url.PathEscape(value)
+	}
+}
+
+// GetQueryVars returns the query string of the row, "?" included, with
+// the names and values escaped unless PreEncoded is set.
 func (conf *Config) GetQueryVars(row []string) (string, error) {
 
 	if len(conf.QueryVars) == 0 {
 		return "", nil
 	}
-	if len(row) < conf.GetTotalColumns() {
+	if conf.columns == nil && len(row) < conf.GetTotalColumns() {
 		return "", fmt.Errorf("not enough columns in the csv")
 	}
 
@@ -150,13 +568,82 @@ func (conf *Config) GetQueryVars(row []string) (string, error) {
 			break
 		}
 
+		value, err := conf.columnValue(row, columnIndex, queryVar)
+		if err != nil {
+			return "", err
+		}
+
 		if j > 0 {
 			urlBuilder.WriteString("&")
 		}
-		urlBuilder.WriteString(util.TrimQuotes(queryVar))
+		name := util.TrimQuotes(queryVar)
+		if !conf.PreEncoded {
+			name, value = url.QueryEscape(name), url.QueryEscape(value)
+		}
+		urlBuilder.WriteString(name)
 		urlBuilder.WriteString("=")
-		urlBuilder.WriteString(util.TrimQuotes(row[columnIndex]))
+		urlBuilder.WriteString(value)
 	}
 
 	return urlBuilder.String(), nil
 }
+
+// AppendQuery adds the query string returned by GetQueryVars to rawURL,
+// after the query rawURL may already have and before its fragment.
+func AppendQuery(rawURL, query string) string {
+	query = strings.TrimPrefix(query, "?")
+	if query == "" {
+		return rawURL
+	}
+	base, fragment, hasFragment := strings.Cut(rawURL, "#")
+	switch {
+	case !strings.Contains(base, "?"):
+		base += "?"
+	case !strings.HasSuffix(base, "?") && !strings.HasSuffix(base, "&"):
+		base += "&"
+	}
+	base += query
+	if hasFragment {
+		base += "#" + fragment
+	}
+	return base
+}
+
+// GetHeaderFields returns the headers of HeaderFields with their row
+// values, leaving out the empty ones.
+func (conf *Config) GetHeaderFields(row []string) (map[string]string, error) {
+	if len(conf.HeaderFields) == 0 {
+		return nil, nil
+	}
+	if conf.columns == nil {
+		return nil, fmt.Errorf("header_fields need a header row")
+	}
+	headers := make(map[string]string, len(conf.HeaderFields))
+	for name, column := range conf.HeaderFields {
+		value, err := conf.columnValue(row, 0, column)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			headers[name] = value
+		}
+	}
+	return headers, nil
+}
+
+// GetBody returns the body column of the row, or an empty string when the
+// request has no body. The body is not trimmed, it is sent as is.
+func (conf *Config) GetBody(row []string) (string, error) {
+	if !conf.HasBody {
+		return "", nil
+	}
+
+	index := len(conf.PathVars) + len(conf.QueryVars)
+	if conf.columns != nil {
+		index = conf.bodyIndex
+	}
+	if index < 0 || index >= len(row) {
+		return "", fmt.Errorf("not enough columns in the csv")
+	}
+	return row[index], nil
+}
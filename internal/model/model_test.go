@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"io"
+	"strings"
 	"testing"
 )
 
@@ -240,6 +241,128 @@ func TestConfig_GetQueryVars(t *testing.T) {
 	}
 }
 
+func TestConfig_BindHeader(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        Config
+		header        []string
+		row           []string
+		expectedURL   string
+		expectedQuery string
+		expectedBody  string
+		expectedError string
+	}{
+		{
+			name: "Columns in a different order",
+			config: Config{
+				ApiEndpoint: "/{userId}",
+				PathVars:    []string{"userId"},
+				QueryVars:   []string{"status", "type"},
+				HasBody:     true,
+				BodyColumn:  "payload",
+			},
+			header:        []string{"payload", "type", "extra", "userId", "status"},
+			row:           []string{`{"a":1}`, "premium", "ignored", "123", "active"},
+			expectedURL:   "/123",
+			expectedQuery: "?status=active&type=premium",
+			expectedBody:  `{"a":1}`,
+		},
+		{
+			name: "Body defaults to the last column",
+			config: Config{
+				ApiEndpoint: "/{id}",
+				PathVars:    []string{"id"},
+				HasBody:     true,
+			},
+			header:       []string{"other", "id", "body"},
+			row:          []string{"x", "42", "the body"},
+			expectedURL:  "/42",
+			expectedBody: "the body",
+		},
+		{
+			name: "Quoted header names",
+			config: Config{
+				ApiEndpoint: "/{id}",
+				PathVars:    []string{"id"},
+			},
+			header:      []string{` "id" `},
+			row:         []string{"7"},
+			expectedURL: "/7",
+		},
+		{
+			name: "Missing columns are all listed",
+			config: Config{
+				PathVars:   []string{"userId"},
+				QueryVars:  []string{"status", "type"},
+				HasBody:    true,
+				BodyColumn: "payload",
+			},
+			header:        []string{"status"},
+			expectedError: "missing columns in the header: userId, type, payload",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.BindHeader(tt.header)
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Fatalf("Expected error %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			url, err := tt.config.WithPathVars(tt.row)
+			if err != nil || url != tt.expectedURL {
+				t.Errorf("Expected URL %q, got %q (err %v)", tt.expectedURL, url, err)
+			}
+			query, err := tt.config.GetQueryVars(tt.row)
+			if err != nil || query != tt.expectedQuery {
+				t.Errorf("Expected query %q, got %q (err %v)", tt.expectedQuery, query, err)
+			}
+			body, err := tt.config.GetBody(tt.row)
+			if err != nil || body != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q (err %v)", tt.expectedBody, body, err)
+			}
+		})
+	}
+}
+
+func TestConfig_BindHeader_ShortRow(t *testing.T) {
+	config := Config{ApiEndpoint: "/{id}", PathVars: []string{"id"}}
+	if err := config.BindHeader([]string{"a", "b", "id"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := config.WithPathVars([]string{"x"}); err == nil {
+		t.Error("Expected error for a row shorter than the header")
+	}
+}
+
+func TestConfig_GetBody(t *testing.T) {
+	config := Config{PathVars: []string{"id"}, QueryVars: []string{"q"}, HasBody: true}
+
+	body, err := config.GetBody([]string{"1", "2", ` {"a":1} `})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if body != ` {"a":1} ` {
+		t.Errorf("Body should be returned untouched, got %q", body)
+	}
+
+	if _, err := config.GetBody([]string{"1", "2"}); err == nil {
+		t.Error("Expected error when the body column is missing")
+	}
+
+	noBody := Config{PathVars: []string{"id"}}
+	if body, err := noBody.GetBody([]string{"1"}); err != nil || body != "" {
+		t.Errorf("Expected empty body without error, got %q, %v", body, err)
+	}
+}
+
 func TestNewCsvRequest(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -459,6 +582,25 @@ func TestResponseType_Values(t *testing.T) {
 	if ERROR != 1 {
 		t.Errorf("Expected ERROR to be 1, got %d", ERROR)
 	}
+
+	if SKIPPED != 2 {
+		t.Errorf("Expected SKIPPED to be 2, got %d", SKIPPED)
+	}
+}
+
+func TestResponseType_String(t *testing.T) {
+	if SUCCESS.String() != "SUCCESS" {
+		t.Errorf("Expected SUCCESS, got %s", SUCCESS.String())
+	}
+	if ERROR.String() != "ERROR" {
+		t.Errorf("Expected ERROR, got %s", ERROR.String())
+	}
+	if SKIPPED.String() != "SKIPPED" {
+		t.Errorf("Expected SKIPPED, got %s", SKIPPED.String())
+	}
+	if ResponseType(42).String() != "ResponseType(42)" {
+		t.Errorf("Unexpected string for unknown type: %s", ResponseType(42).String())
+	}
 }
 
 func TestCsvRequest_CompleteWorkflow(t *testing.T) {
@@ -631,3 +773,132 @@ func TestCsvRequest_MultipleOptionsOrder(t *testing.T) {
 		t.Error("URL should be the same regardless of option order")
 	}
 }
+
+func TestConfig_ExtractColumns(t *testing.T) {
+	config := Config{Extract: []Extraction{
+		{Column: "order_id", JSONPath: "$.id"},
+		{Column: "etag", Header: "ETag"},
+	}}
+
+	columns := config.ExtractColumns()
+	if len(columns) != 2 || columns[0] != "order_id" || columns[1] != "etag" {
+		t.Errorf("Expected [order_id etag], got %v", columns)
+	}
+	if columns := (&Config{}).ExtractColumns(); len(columns) != 0 {
+		t.Errorf("Expected no columns, got %v", columns)
+	}
+}
+
+func TestConfig_RowValues(t *testing.T) {
+	config := Config{PathVars: []string{"id"}, QueryVars: []string{"lang"}, HasBody: true}
+	values := config.RowValues([]string{`"7"`, "en", `{"a":1}`})
+	if values["id"] != "7" || values["lang"] != "en" || values["body"] != `{"a":1}` {
+		t.Errorf("Unexpected positional values %v", values)
+	}
+
+	config = Config{PathVars: []string{"id"}, HasHeader: true}
+	if err := config.BindHeader([]string{"name", "id"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	values = config.RowValues([]string{"Ada", "7"})
+	if len(values) != 2 || values["name"] != "Ada" || values["id"] != "7" {
+		t.Errorf("Expected every header column, got %v", values)
+	}
+}
+
+func TestConfig_URLEncoding(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        Config
+		row           []string
+		expectedURL   string
+		expectedQuery string
+	}{
+		{
+			name: "Reserved characters are escaped",
+			config: Config{
+				ApiEndpoint: "https://api.example.com/files/{path}",
+				PathVars:    []string{"path"},
+				QueryVars:   []string{"q", "a&b"},
+			},
+			row:           []string{"dir/a b#1", "x=1&y=2", "café"},
+			expectedURL:   "https://api.example.com/files/dir%2Fa%20b%231",
+			expectedQuery: "?q=x%3D1%26y%3D2&a%26b=caf%C3%A9",
+		},
+		{
+			name: "Path vars in the query string of the endpoint",
+			config: Config{
+				ApiEndpoint: "https://api.example.com/{id}/search?term={term}",
+				PathVars:    []string{"id", "term"},
+			},
+			row:         []string{"a b", "a b"},
+			expectedURL: "https://api.example.com/a%20b/search?term=a+b",
+		},
+		{
+			name: "Pre-encoded values are kept",
+			config: Config{
+				ApiEndpoint: "https://api.example.com/files/{path}",
+				PathVars:    []string{"path"},
+				QueryVars:   []string{"q"},
+				PreEncoded:  true,
+			},
+			row:           []string{"dir%2Fa", "a%20b"},
+			expectedURL:   "https://api.example.com/files/dir%2Fa",
+			expectedQuery: "?q=a%20b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, err := tt.config.WithPathVars(tt.row)
+			if err != nil || url != tt.expectedURL {
+				t.Errorf("Expected URL %q, got %q (err %v)", tt.expectedURL, url, err)
+			}
+			query, err := tt.config.GetQueryVars(tt.row)
+			if err != nil || query != tt.expectedQuery {
+				t.Errorf("Expected query %q, got %q (err %v)", tt.expectedQuery, query, err)
+			}
+		})
+	}
+}
+
+func TestAppendQuery(t *testing.T) {
+	tests := []struct {
+		rawURL   string
+		query    string
+		expected string
+	}{
+		{rawURL: "https://api.example.com/items", query: "?a=1", expected: "https://api.example.com/items?a=1"},
+		{rawURL: "https://api.example.com/items?v=2", query: "?a=1", expected: "https://api.example.com/items?v=2&a=1"},
+		{rawURL: "https://api.example.com/items?", query: "?a=1", expected: "https://api.example.com/items?a=1"},
+		{rawURL: "https://api.example.com/items#top", query: "?a=1", expected: "https://api.example.com/items?a=1#top"},
+		{rawURL: "https://api.example.com/items", query: "", expected: "https://api.example.com/items"},
+	}
+
+	for _, tt := range tests {
+		if result := AppendQuery(tt.rawURL, tt.query); result != tt.expected {
+			t.Errorf("AppendQuery(%q, %q): expected %q, got %q", tt.rawURL, tt.query, tt.expected, result)
+		}
+	}
+}
+
+func TestConfig_GetHeaderFields(t *testing.T) {
+	config := Config{HeaderFields: map[string]string{"X-Tenant": "tenant", "X-Trace": "trace"}}
+	if _, err := config.GetHeaderFields([]string{"acme", "t-1"}); err == nil {
+		t.Error("Expected an error without a header row")
+	}
+
+	if err := config.BindHeader([]string{"trace", "id"}); err == nil || !strings.Contains(err.Error(), "tenant") {
+		t.Errorf("Expected the missing header field column, got %v", err)
+	}
+	if err := config.BindHeader([]string{"tenant", "trace"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	headers, err := config.GetHeaderFields([]string{"acme", ""})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(headers) != 1 || headers["X-Tenant"] != "acme" {
+		t.Errorf("Expected only the non-empty header, got %v", headers)
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// responseAssertions holds the compiled assertions and response schema.
+type responseAssertions struct {
+	checks []assertion
+	schema *jsonSchema
+}
+
+type assertion struct {
+	name      string
+	path      jsonPath
+	regex     *regexp.Regexp
+	equals    any
+	hasEquals bool
+	notEquals any
+	hasNot    bool
+}
+
+func newResponseAssertions(config model.Config) (*responseAssertions, error) {
+	assertions := &responseAssertions{}
+	for i, conf := range config.Assertions {
+		check, err := compileAssertion(conf)
+		if err != nil {
+			return nil, fmt.Errorf("invalid assertion %d: %w", i+1, err)
+		}
+		assertions.checks = append(assertions.checks, check)
+	}
+
+	if config.ResponseSchema != "" {
+		schema, err := loadJSONSchema(config.ResponseSchema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response_schema: %w", err)
+		}
+		assertions.schema = schema
+	}
+	return assertions, nil
+}
+
+func compileAssertion(conf model.Assertion) (assertion, error) {
+	check := assertion{name: conf.Name}
+	if conf.JSONPath != "" {
+		path, err := parseJSONPath(conf.JSONPath)
+		if err != nil {
+			return check, err
+		}
+		check.path = path
+	}
+	if conf.Regex != "" {
+		regex, err := regexp.Compile(conf.Regex)
+		if err != nil {
+			return check, fmt.Errorf("invalid regex: %w", err)
+		}
+		check.regex = regex
+	}
+	if conf.Equals != nil || conf.NotEquals != nil {
+		if check.path == nil {
+			return check, errors.New("equals and not_equals require json_path")
+		}
+	}
+	if conf.Equals != nil {
+		if err := decodeJSON(conf.Equals, &check.equals); err != nil {
+			return check, fmt.Errorf("invalid equals: %w", err)
+		}
+		check.hasEquals = true
+	}
+	if conf.NotEquals != nil {
+		if err := decodeJSON(conf.NotEquals, &check.notEquals); err != nil {
+			return check, fmt.Errorf("invalid not_equals: %w", err)
+		}
+		check.hasNot = true
+	}
+	if check.path == nil && check.regex == nil {
+		return check, errors.New("assertion needs json_path or regex")
+	}
+	if check.name == "" {
+		check.name = conf.JSONPath
+		if check.name == "" {
+			check.name = conf.Regex
+		}
+	}
+	return check, nil
+}
+
+// check runs the assertions and then the schema validation on the body,
+// returning an error describing the first one that fails.
+func (a *responseAssertions) check(body []byte) error {
+	parsed := &responseBody{raw: body}
+	for _, check := range a.checks {
+		if err := check.run(parsed); err != nil {
+			return fmt.Errorf("assertion %q failed: %w", check.name, err)
+		}
+	}
+
+	if a.schema != nil {
+		doc, ok := parsed.json()
+		if !ok {
+			return errors.New("schema validation failed: body is not valid JSON")
+		}
+		if err := a.schema.validate(doc); err != nil {
+			return fmt.Errorf("schema validation failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c assertion) run(body *responseBody) error {
+	if c.path == nil {
+		if !c.regex.Match(body.raw) {
+			return fmt.Errorf("body does not match %q", c.regex)
+		}
+		return nil
+	}
+
+	doc, ok := body.json()
+	if !ok {
+		return errors.New("body is not valid JSON")
+	}
+	value, found := c.path.lookup(doc)
+	if !found {
+		// a value that must only differ from not_equals may be absent
+		if c.hasNot && !c.hasEquals && c.regex == nil {
+			return nil
+		}
+		return errors.New("value not found")
+	}
+	if c.hasEquals && !jsonEqual(value, c.equals) {
+		return fmt.Errorf("got %s, expected %s", formatJSONValue(value), formatJSONValue(c.equals))
+	}
+	if c.hasNot && jsonEqual(value, c.notEquals) {
+		return fmt.Errorf("got %s, which is not allowed", formatJSONValue(value))
+	}
+	if c.regex != nil {
+		text, isString := value.(string)
+		if !isString {
+			text = formatJSONValue(value)
+		}
+		if !c.regex.MatchString(text) {
+			return fmt.Errorf("%q does not match %q", text, c.regex)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResponseAssertions_Check(t *testing.T) {
+	assertions, err := newResponseAssertions(model.Config{Assertions: []model.Assertion{
+		{Name: "status ok", JSONPath: "$.status", Equals: json.RawMessage(`"OK"`)},
+		{JSONPath: "$.errors", NotEquals: json.RawMessage(`true`)},
+		{JSONPath: "$.id", Regex: `^[0-9]+$`},
+		{JSONPath: "$.version", NotEquals: json.RawMessage(`9007199254740993`)},
+		{Regex: `"status"`},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "all pass", body: `{"status":"OK","errors":false,"id":42,"version":9007199254740992}`},
+		{name: "big id", body: `{"status":"OK","id":9007199254740993}`},
+		{name: "equals fails", body: `{"status":"FAILED","id":1}`, wantErr: `assertion "status ok" failed: got "FAILED", expected "OK"`},
+		{name: "not equals fails", body: `{"status":"OK","errors":true,"id":1}`, wantErr: `assertion "$.errors" failed: got true, which is not allowed`},
+		{name: "big number not equals fails", body: `{"status":"OK","id":1,"version":9007199254740993.0}`, wantErr: `assertion "$.version" failed: got 9007199254740993.0, which is not allowed`},
+		{name: "regex on value fails", body: `{"status":"OK","id":"abc"}`, wantErr: `assertion "$.id" failed: "abc" does not match`},
+		{name: "missing value", body: `{"status":"OK"}`, wantErr: `assertion "$.id" failed: value not found`},
+		{name: "not json", body: `OK`, wantErr: `assertion "status ok" failed: body is not valid JSON`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := assertions.check([]byte(tt.body))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestResponseAssertions_Schema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	os.WriteFile(path, []byte(`{"type":"object","required":["id"]}`), 0644)
+
+	assertions, err := newResponseAssertions(model.Config{ResponseSchema: path})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := assertions.check([]byte(`{"id":1}`)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := assertions.check([]byte(`{}`)); err == nil || !strings.Contains(err.Error(), `schema validation failed: $: missing required property "id"`) {
+		t.Errorf("Expected a schema error, got %v", err)
+	}
+	if err := assertions.check([]byte(`<html>`)); err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Errorf("Expected an invalid JSON error, got %v", err)
+	}
+}
+
+func TestNewResponseAssertions_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config model.Config
+	}{
+		{name: "no condition", config: model.Config{Assertions: []model.Assertion{{Name: "empty"}}}},
+		{name: "equals without path", config: model.Config{Assertions: []model.Assertion{{Regex: "a", Equals: json.RawMessage(`1`)}}}},
+		{name: "bad regex", config: model.Config{Assertions: []model.Assertion{{Regex: "("}}}},
+		{name: "bad path", config: model.Config{Assertions: []model.Assertion{{JSONPath: "$.a["}}}},
+		{name: "missing schema", config: model.Config{ResponseSchema: filepath.Join(t.TempDir(), "missing.json")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newResponseAssertions(tt.config); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestProcessService_processRecord_AssertionFailed(t *testing.T) {
+	service := &ProcessService{
+		config: model.Config{Assertions: []model.Assertion{
+			{JSONPath: "$.status", Equals: json.RawMessage(`"OK"`)},
+		}},
+		httpService: &MockHttpService{callFunc: func(record http.Request) ([]byte, int, error) {
+			return []byte(`{"status":"FAILED"}`), 200, nil
+		}},
+	}
+
+	response, err := service.processRecord(context.Background(), *createTestRequest("https://api.example.com"), 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.Type != model.ERROR {
+		t.Errorf("A failed assertion should turn the response into an error, got %v", response.Type)
+	}
+	expected := `0-200 [attempts=1] - assertion "$.status" failed: got "FAILED", expected "OK" - {"status":"FAILED"}`
+	if response.Message != expected {
+		t.Errorf("Expected message %q, got %q", expected, response.Message)
+	}
+	if !strings.Contains(response.Error, "assertion") {
+		t.Errorf("Expected the failure in Error, got %q", response.Error)
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CheckpointSuffix is appended to the input file path to name the checkpoint file.
+const CheckpointSuffix = ".checkpoint"
+
+// Checkpoint records which rows completed and with what status, one
+// "<index>\t<status>\t<type>" line per row, so an interrupted run can resume.
+// A nil Checkpoint is valid and records nothing.
+// Only the rows of the previous runs are kept in memory; the rows of the
+// current run are counted, so memory does not grow with the input.
+type Checkpoint struct {
+	mu       sync.Mutex
+	file     *os.File
+	done     map[int]bool
+	recorded int
+}
+
+// OpenCheckpoint opens the checkpoint file at path. When resume is true the
+// rows already listed are loaded and new entries are appended, otherwise the
+// file is started from scratch.
+func OpenCheckpoint(path string, resume bool) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{done: make(map[int]bool)}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		if err := checkpoint.load(path); err != nil {
+			return nil, err
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening checkpoint: %w", err)
+	}
+	checkpoint.file = file
+	return checkpoint, nil
+}
+
+func (c *Checkpoint) load(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening checkpoint: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			// A crash can leave a truncated last line behind
+			continue
+		}
+		c.done[index] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading checkpoint: %w", err)
+	}
+	return nil
+}
+
+// IsDone reports whether the row completed in a previous run.
+func (c *Checkpoint) IsDone(index int) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done[index]
+}
+
+// Completed returns how many rows are recorded as done, in the previous
+// runs and in this one.
+func (c *Checkpoint) Completed() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.done) + c.recorded
+}
+
+// Record writes the entry of the row to the checkpoint file. A row recorded
+// in this run is never sent again, so IsDone does not need to know about it.
+func (c *Checkpoint) Record(index int, response model.Response) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	line := fmt.Sprintf("%d\t%d\t%s\n", index, response.Status, response.Type)
+	if _, err := c.file.WriteString(line); err != nil {
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	c.recorded++
+	return nil
+}
+
+// Sync commits the checkpoint file to stable storage.
+func (c *Checkpoint) Sync() error {
+	if c == nil {
+		return nil
+	}
+	if err := c.file.Sync(); err != nil {
+		return fmt.Errorf("error syncing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Close closes the checkpoint file.
+func (c *Checkpoint) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.file.Close()
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenCheckpoint_RecordAndResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.tsv"+CheckpointSuffix)
+
+	checkpoint, err := OpenCheckpoint(path, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := checkpoint.Record(0, model.Response{Type: model.SUCCESS, Status: 200}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := checkpoint.Record(2, model.Response{Type: model.ERROR, Status: 500}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkpoint.Close()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	expected := "0\t200\tSUCCESS\n2\t500\tERROR\n"
+	if string(content) != expected {
+		t.Errorf("Expected checkpoint content %q, got %q", expected, string(content))
+	}
+
+	resumed, err := OpenCheckpoint(path, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resumed.Close()
+
+	if !resumed.IsDone(0) || !resumed.IsDone(2) {
+		t.Error("Rows recorded in the previous run should be done")
+	}
+	if resumed.IsDone(1) {
+		t.Error("Row 1 was never recorded")
+	}
+	if resumed.Completed() != 2 {
+		t.Errorf("Expected 2 completed rows, got %d", resumed.Completed())
+	}
+
+	if err := resumed.Record(1, model.Response{Type: model.SUCCESS, Status: 201}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resumed.IsDone(1) || resumed.Completed() != 3 {
+		t.Errorf("Rows of the current run should be counted but not kept, got %d completed", resumed.Completed())
+	}
+	content, _ = os.ReadFile(path)
+	if string(content) != expected+"1\t201\tSUCCESS\n" {
+		t.Errorf("Resume should append to the checkpoint, got %q", string(content))
+	}
+}
+
+func TestOpenCheckpoint_FreshRunTruncates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.tsv"+CheckpointSuffix)
+	if err := os.WriteFile(path, []byte("0\t200\tSUCCESS\n"), 0644); err != nil {
+		t.Fatalf("Failed to write checkpoint: %v", err)
+	}
+
+	checkpoint, err := OpenCheckpoint(path, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer checkpoint.Close()
+
+	if checkpoint.IsDone(0) {
+		t.Error("A fresh run should ignore the previous checkpoint")
+	}
+	content, _ := os.ReadFile(path)
+	if len(content) != 0 {
+		t.Errorf("Expected the checkpoint to be truncated, got %q", string(content))
+	}
+}
+
+func TestOpenCheckpoint_ResumeIgnoresTruncatedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.tsv"+CheckpointSuffix)
+	if err := os.WriteFile(path, []byte("0\t200\tSUCCESS\n1\t200\tSUCCESS\n\n2\t2"), 0644); err != nil {
+		t.Fatalf("Failed to write checkpoint: %v", err)
+	}
+
+	checkpoint, err := OpenCheckpoint(path, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer checkpoint.Close()
+
+	if checkpoint.Completed() != 3 {
+		t.Errorf("Expected 3 completed rows, got %d", checkpoint.Completed())
+	}
+}
+
+func TestOpenCheckpoint_ResumeWithoutFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing"+CheckpointSuffix)
+
+	checkpoint, err := OpenCheckpoint(path, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer checkpoint.Close()
+
+	if checkpoint.Completed() != 0 {
+		t.Errorf("Expected no completed rows, got %d", checkpoint.Completed())
+	}
+}
+
+func TestCheckpoint_Nil(t *testing.T) {
+	var checkpoint *Checkpoint
+
+	if checkpoint.IsDone(0) {
+		t.Error("A nil checkpoint has no completed rows")
+	}
+	if err := checkpoint.Record(0, model.Response{}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := checkpoint.Close(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,433 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// curlIgnoredFlags are the curl options without value that do not change
+// the request sent. TLS and redirects follow the config of the run.
+var curlIgnoredFlags = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true,
+	"-k": true, "--insecure": true, "-L": true, "--location": true,
+	"-i": true, "--include": true, "-v": true, "--verbose": true,
+	"-f": true, "--fail": true, "--fail-with-body": true, "--compressed": true,
+	"-g": true, "--globoff": true, "-N": true, "--no-buffer": true,
+	"-#": true, "--progress-bar": true, "--http1.1": true, "--http2": true,
+}
+
+// curlIgnoredOptions are the curl options whose value does not change the
+// request sent.
+var curlIgnoredOptions = map[string]bool{
+	"-o": true, "--output": true, "-w": true, "--write-out": true,
+	"-m": true, "--max-time": true, "--connect-timeout": true,
+	"--retry": true, "--retry-delay": true, "--retry-max-time": true,
+	"-x": true, "--proxy": true, "--cacert": true, "-E": true, "--cert": true,
+	"--key": true, "-c": true, "--cookie-jar": true, "--max-redirs": true,
+}
+
+// curlValueOptions are the supported curl options taking a value.
+var curlValueOptions = map[string]bool{
+	"-X": true, "--request": true, "-H": true, "--header": true,
+	"-d": true, "--data": true, "--data-ascii": true, "--data-binary": true, "--data-raw": true,
+	"--url": true, "-u": true, "--user": true,
+	"-A": true, "--user-agent": true, "-e": true, "--referer": true, "-b": true, "--cookie": true,
+}
+
+// curlCommand is a request described by the arguments of a curl command.
+type curlCommand struct {
+	method  string
+	url     string
+	headers [][2]string
+	data    []string
+	user    *string
+	get     bool
+	head    bool
+}
+
+// curlRecords streams the requests of a list of curl commands, such as the
+// ones copied from logs or from the network tab of a browser. Commands may
+// span several lines with backslash continuations or quoted arguments;
+// blank lines and # comments between commands are skipped. The row of a
+// record is the method and the URL, its raw text the whole command.
+func (s *ParserService) curlRecords(input io.Reader) iter.Seq2[model.Record, error] {
+	return func(yield func(model.Record, error) bool) {
+		if len(s.config.Steps) > 0 {
+			yield(model.Record{}, errors.New("steps are not supported with curl input"))
+			return
+		}
+		reader := bufio.NewReader(input)
+
+		var text []byte
+		index, line, start := 0, 0, 0
+		for {
+			raw, err := reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				yield(model.Record{}, fmt.Errorf("error reading line: %w", err))
+				return
+			}
+			if len(raw) == 0 && err == io.EOF {
+				if len(text) > 0 {
+					yield(model.Record{}, fmt.Errorf("line %d: unterminated curl command", start))
+				}
+				return
+			}
+			line++
+
+			if len(text) == 0 {
+				trimmed := bytes.TrimSpace(raw)
+				if len(trimmed) == 0 || trimmed[0] == '#' {
+					continue
+				}
+				start = line
+			}
+			text = append(text, raw...)
+
+			args, complete := splitShellWords(string(text))
+			if !complete {
+				continue
+			}
+			command := text
+			text = nil
+			if len(args) == 0 {
+				continue
+			}
+
+			request, row, parseErr := s.curlRequest(args)
+			if parseErr != nil {
+				yield(model.Record{}, fmt.Errorf("line %d: %w", start, parseErr))
+				return
+			}
+			if !yield(model.Record{Index: index, Request: request, Row: row, Raw: command}, nil) {
+				return
+			}
+			index++
+		}
+	}
+}
+
+// curlRequest builds the request of the arguments of a curl command, on top
+// of which the config headers are set.
+func (s *ParserService) curlRequest(args []string) (*http.Request, []string, error) {
+	command, err := parseCurlArgs(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawURL := command.url
+	if !strings.Contains(rawURL, "://") {
+		// like curl, a URL without scheme is an http one
+		rawURL = "http://" + rawURL
+	}
+	body := strings.Join(command.data, "&")
+	if command.get {
+		rawURL = model.AppendQuery(rawURL, body)
+		body = ""
+	}
+
+	method := command.method
+	switch {
+	case method != "":
+	case command.head:
+		method = http.MethodHead
+	case len(command.data) > 0 && !command.get:
+		method = http.MethodPost
+	default:
+		method = http.MethodGet
+	}
+
+	request, err := http.NewRequest(method, rawURL, strings.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, header := range command.headers {
+		request.Header.Add(header[0], header[1])
+	}
+	if body != "" && request.Header.Get("Content-Type") == "" {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if command.user != nil {
+		user, password, _ := strings.Cut(*command.user, ":")
+		request.SetBasicAuth(user, password)
+	}
+	for name, value := range s.config.Headers {
+		request.Header.Set(name, value)
+	}
+	return request, []string{method, rawURL}, nil
+}
+
+// parseCurlArgs reads the options of a curl command. Options that only
+// change how curl runs are ignored, unknown ones are an error so a request
+// is never sent differently from what the command says.
+func parseCurlArgs(args []string) (*curlCommand, error) {
+	if args[0] != "curl" {
+		return nil, fmt.Errorf("not a curl command: %s", args[0])
+	}
+	command := &curlCommand{}
+	args = splitCurlOptions(args[1:])
+	for i := 0; i < len(args); i++ {
+		option := args[i]
+		switch {
+		case !strings.HasPrefix(option, "-") || option == "-":
+			if command.url != "" {
+				return nil, fmt.Errorf("more than one URL: %s", option)
+			}
+			command.url = option
+		case curlIgnoredFlags[option]:
+		case option == "-G" || option == "--get":
+			command.get = true
+		case option == "-I" || option == "--head":
+			command.head = true
+		case curlValueOptions[option] || curlIgnoredOptions[option]:
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("curl option %s needs a value", option)
+			}
+			i++
+			if err := command.set(option, args[i]); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported curl option %s", option)
+		}
+	}
+
+	if command.url == "" {
+		return nil, errors.New("curl command without URL")
+	}
+	return command, nil
+}
+
+// splitCurlOptions separates joined short options, so -sSL becomes -s -S -L
+// and -XPOST becomes -X POST.
+func splitCurlOptions(args []string) []string {
+	var split []string
+	takesValue := false
+	for _, arg := range args {
+		if takesValue || len(arg) <= 2 || arg[0] != '-' || arg[1] == '-' {
+			split = append(split, arg)
+			takesValue = !takesValue && (curlValueOptions[arg] || curlIgnoredOptions[arg])
+			continue
+		}
+		for i := 1; i < len(arg); i++ {
+			option := "-" + arg[i:i+1]
+			split = append(split, option)
+			if curlValueOptions[option] || curlIgnoredOptions[option] {
+				if i+1 < len(arg) {
+					split = append(split, arg[i+1:])
+				} else {
+					takesValue = true
+				}
+				break
+			}
+		}
+	}
+	return split
+}
+
+func (c *curlCommand) set(option, value string) error {
+	switch option {
+	case "-X", "--request":
+		c.method = value
+	case "--url":
+		if c.url != "" {
+			return fmt.Errorf("more than one URL: %s", value)
+		}
+		c.url = value
+	case "-H", "--header":
+		name, headerValue, found := strings.Cut(value, ":")
+		switch {
+		case found && strings.TrimSpace(headerValue) != "":
+			c.headers = append(c.headers, [2]string{strings.TrimSpace(name), strings.TrimSpace(headerValue)})
+		case !found && strings.HasSuffix(value, ";"):
+			// "Name;" is how curl sends a header with an empty value
+			c.headers = append(c.headers, [2]string{strings.TrimSuffix(value, ";"), ""})
+		case !found:
+			return fmt.Errorf("invalid curl header %q", value)
+		}
+	case "-d", "--data", "--data-ascii", "--data-binary":
+		if strings.HasPrefix(value, "@") {
+			return fmt.Errorf("reading data from a file is not supported: %s %s", option, value)
+		}
+		if option != "--data-binary" {
+			// curl strips the line breaks of -d data
+			value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+		}
+		c.data = append(c.data, value)
+	case "--data-raw":
+		c.data = append(c.data, value)
+	case "-u", "--user":
+		c.user = &value
+	case "-A", "--user-agent":
+		c.headers = append(c.headers, [2]string{"User-Agent", value})
+	case "-e", "--referer":
+		c.headers = append(c.headers, [2]string{"Referer", value})
+	case "-b", "--cookie":
+		if !strings.Contains(value, "=") {
+			return fmt.Errorf("reading cookies from a file is not supported: %s %s", option, value)
+		}
+		c.headers = append(c.headers, [2]string{"Cookie", value})
+	}
+	return nil
+}
+
+// splitShellWords splits a command line into its arguments the way a POSIX
+// shell does, with single, double and $'...' quotes, backslash escapes and
+// line continuations, and # comments. It reports the command as incomplete
+// when it ends inside quotes or with a line continuation.
+func splitShellWords(text string) ([]string, bool) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '#' && !inWord:
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				return args, true
+			}
+			i += end
+		case c == '\\':
+			if i+1 == len(text) || text[i+1:] == "\r" {
+				return nil, false
+			}
+			i++
+			if text[i] == '\r' && text[i+1] == '\n' {
+				i++
+			}
+			if text[i] == '\n' {
+				// line continuation, the command goes on on the next line
+				if i+1 == len(text) {
+					return nil, false
+				}
+				continue
+			}
+			word.WriteByte(text[i])
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(text[i+1:], '\'')
+			if end < 0 {
+				return nil, false
+			}
+			word.WriteString(text[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			end, ok := readDoubleQuoted(text[i+1:], &word)
+			if !ok {
+				return nil, false
+			}
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(text) && text[i+1] == '\'':
+			end, ok := readANSIQuoted(text[i+2:], &word)
+			if !ok {
+				return nil, false
+			}
+			i += end + 2
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, true
+}
+
+// readDoubleQuoted writes the text of a double quoted string to word and
+// returns the position of its closing quote. A backslash only escapes
+// $, `, ", \ and line breaks.
+func readDoubleQuoted(text string, word *strings.Builder) (int, bool) {
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '"':
+			return i, true
+		case '\\':
+			if i+1 == len(text) {
+				return 0, false
+			}
+			switch next := text[i+1]; next {
+			case '$', '`', '"', '\\':
+				word.WriteByte(next)
+				i++
+			case '\n':
+				i++
+			default:
+				word.WriteByte(c)
+			}
+		default:
+			word.WriteByte(c)
+		}
+	}
+	return 0, false
+}
+
+// readANSIQuoted writes the text of a $'...' string, as written by the
+// "Copy as cURL" of browsers, to word and returns the position of its
+// closing quote.
+func readANSIQuoted(text string, word *strings.Builder) (int, bool) {
+	escapes := map[byte]string{
+		'n': "\n", 't': "\t", 'r': "\r", '\\': "\\", '\'': "'", '"': "\"",
+		'a': "\a", 'b': "\b", 'e': "\x1b", 'f': "\f", 'v': "\v",
+	}
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c == '\'' {
+			return i, true
+		}
+		if c != '\\' {
+			word.WriteByte(c)
+			continue
+		}
+		if i+1 == len(text) {
+			return 0, false
+		}
+		i++
+		if escaped, ok := escapes[text[i]]; ok {
+			word.WriteString(escaped)
+			continue
+		}
+		if count, ok := digits[text[i]]; ok {
+			end := i + 1
+			for end < len(text) && end <= i+count && isHexDigit(text[end]) {
+				end++
+			}
+			if code, err := strconv.ParseUint(text[i+1:end], 16, 32); err == nil {
+				if text[i] == 'x' {
+					word.WriteByte(byte(code))
+				} else {
+					word.WriteRune(rune(code))
+				}
+				i = end - 1
+				continue
+			}
+		}
+		word.WriteByte('\\')
+		word.WriteByte(text[i])
+	}
+	return 0, false
+}
+
+func isHexDigit(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
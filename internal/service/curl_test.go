@@ -0,0 +1,158 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCurlCommands = `# orders that timed out
+curl -X PUT 'https://api.example.com/orders/1' \
+  -H 'Content-Type: application/json' \
+  -H "Authorization: Bearer expired" \
+  --data-raw '{"status": "paid",
+  "note": "it'\''s done"}'
+
+curl -sS https://api.example.com/search -G -d q=a+b -d page=2
+
+curl --url "https://api.example.com/login" -u "admin:s3cr\$t" -d user=admin --compressed
+`
+
+func TestParserService_Records_Curl(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "commands.curl")
+	if err := os.WriteFile(testFile, []byte(testCurlCommands), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	service := NewParserService(model.Config{Headers: map[string]string{"Authorization": "Bearer fresh"}})
+	var records []model.Record
+	for record, err := range service.Records(testFile) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+
+	order := records[0].Request
+	if order.Method != "PUT" || order.URL.String() != "https://api.example.com/orders/1" {
+		t.Errorf("Unexpected request %s %s", order.Method, order.URL)
+	}
+	if order.Header.Get("Content-Type") != "application/json" || order.Header.Get("Authorization") != "Bearer fresh" {
+		t.Errorf("Unexpected headers %v", order.Header)
+	}
+	body, _ := io.ReadAll(order.Body)
+	if string(body) != "{\"status\": \"paid\",\n  \"note\": \"it's done\"}" {
+		t.Errorf("Unexpected body %q", body)
+	}
+	if !strings.HasPrefix(string(records[0].Raw), "curl -X PUT") || !strings.HasSuffix(string(records[0].Raw), "done\"}'\n") {
+		t.Errorf("Expected the whole command as raw text, got %q", records[0].Raw)
+	}
+
+	search := records[1]
+	if search.Request.Method != "GET" || search.Request.URL.String() != "https://api.example.com/search?q=a+b&page=2" {
+		t.Errorf("Unexpected request %s %s", search.Request.Method, search.Request.URL)
+	}
+	if search.Index != 1 || !reflect.DeepEqual(search.Row, []string{"GET", "https://api.example.com/search?q=a+b&page=2"}) {
+		t.Errorf("Unexpected record %d %v", search.Index, search.Row)
+	}
+
+	// the Authorization header of the config replaces the one of -u too
+	if records[2].Request.Header.Get("Authorization") != "Bearer fresh" {
+		t.Errorf("Expected the config header, got %q", records[2].Request.Header.Get("Authorization"))
+	}
+	args, _ := splitShellWords(strings.SplitAfter(testCurlCommands, "\n\n")[2])
+	login, _, err := NewParserService(model.Config{}).curlRequest(args)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	user, password, ok := login.BasicAuth()
+	if login.Method != "POST" || !ok || user != "admin" || password != "s3cr$t" {
+		t.Errorf("Unexpected request %s with user %q and password %q", login.Method, user, password)
+	}
+	if login.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		t.Errorf("Expected a form content type, got %q", login.Header.Get("Content-Type"))
+	}
+}
+
+func TestParseCurlArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		expected curlCommand
+	}{
+		{
+			name:     "joined short options",
+			command:  `curl -sSLXPOST -Hx-id:1 example.com`,
+			expected: curlCommand{method: "POST", url: "example.com", headers: [][2]string{{"x-id", "1"}}},
+		},
+		{
+			name:     "values starting with a dash",
+			command:  `curl -d -1 -o -out.txt https://example.com`,
+			expected: curlCommand{url: "https://example.com", data: []string{"-1"}},
+		},
+		{
+			name:     "browser quoting",
+			command:  `curl 'https://example.com' -H 'accept: */*' -H 'x-empty;' -b 'sid=1' --data-raw $'{"a":"é\n"}'`,
+			expected: curlCommand{url: "https://example.com", headers: [][2]string{{"accept", "*/*"}, {"x-empty", ""}, {"Cookie", "sid=1"}}, data: []string{"{\"a\":\"é\n\"}"}},
+		},
+		{
+			name:     "head request",
+			command:  `curl -I -A agent https://example.com`,
+			expected: curlCommand{url: "https://example.com", headers: [][2]string{{"User-Agent", "agent"}}, head: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, complete := splitShellWords(tt.command)
+			if !complete {
+				t.Fatalf("Expected a complete command")
+			}
+			command, err := parseCurlArgs(args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(*command, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, *command)
+			}
+		})
+	}
+}
+
+func TestParserService_Records_CurlErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "not curl", content: "\nwget https://example.com\n", expected: "line 2: not a curl command"},
+		{name: "unknown option", content: "curl --digest https://example.com", expected: "unsupported curl option --digest"},
+		{name: "data file", content: "curl -d @body.json https://example.com", expected: "reading data from a file is not supported"},
+		{name: "no URL", content: "curl -X POST", expected: "curl command without URL"},
+		{name: "missing value", content: "curl https://example.com -H", expected: "needs a value"},
+		{name: "unterminated quote", content: "curl 'https://example.com\n", expected: "line 1: unterminated curl command"},
+		{name: "dangling continuation", content: "curl https://example.com \\\n", expected: "unterminated curl command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewParserService(model.Config{})
+			var err error
+			for _, err = range service.curlRecords(strings.NewReader(tt.content)) {
+				if err != nil {
+					break
+				}
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// responseExtractor takes the extract columns out of a response.
+type responseExtractor struct {
+	extractions []extraction
+}
+
+type extraction struct {
+	path   jsonPath
+	header string
+	regex  *regexp.Regexp
+}
+
+func newResponseExtractor(config []model.Extraction) (*responseExtractor, error) {
+	extractor := &responseExtractor{}
+	for i, conf := range config {
+		e, err := compileExtraction(conf)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extract %d: %w", i+1, err)
+		}
+		extractor.extractions = append(extractor.extractions, e)
+	}
+	return extractor, nil
+}
+
+func compileExtraction(conf model.Extraction) (extraction, error) {
+	e := extraction{header: conf.Header}
+	if conf.Column == "" {
+		return e, errors.New("column is required")
+	}
+
+	sources := 0
+	if conf.JSONPath != "" {
+		path, err := parseJSONPath(conf.JSONPath)
+		if err != nil {
+			return e, err
+		}
+		e.path = path
+		sources++
+	}
+	if conf.Header != "" {
+		sources++
+	}
+	if conf.Regex != "" {
+		regex, err := regexp.Compile(conf.Regex)
+		if err != nil {
+			return e, fmt.Errorf("invalid regex: %w", err)
+		}
+		e.regex = regex
+		sources++
+	}
+	if sources != 1 {
+		return e, fmt.Errorf("column %q needs exactly one of json_path, header or regex", conf.Column)
+	}
+	return e, nil
+}
+
+// extract returns one value per extract column, empty when not found.
+func (x *responseExtractor) extract(header http.Header, body []byte) []string {
+	if len(x.extractions) == 0 {
+		return nil
+	}
+	parsed := &responseBody{raw: body}
+	values := make([]string, len(x.extractions))
+	for i, e := range x.extractions {
+		values[i] = e.value(header, parsed)
+	}
+	return values
+}
+
+func (e extraction) value(header http.Header, body *responseBody) string {
+	switch {
+	case e.path != nil:
+		doc, ok := body.json()
+		if !ok {
+			return ""
+		}
+		value, found := e.path.lookup(doc)
+		if !found || value == nil {
+			return ""
+		}
+		switch typed := value.(type) {
+		case string:
+			return typed
+		case json.Number:
+			// the number as written, big IDs included
+			return typed.String()
+		}
+		return formatJSONValue(value)
+	case e.regex != nil:
+		match := e.regex.FindSubmatch(body.raw)
+		if match == nil {
+			return ""
+		}
+		if len(match) > 1 {
+			return string(match[1])
+		}
+		return string(match[0])
+	default:
+		return header.Get(e.header)
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestResponseExtractor_Extract(t *testing.T) {
+	extractor, err := newResponseExtractor([]model.Extraction{
+		{Column: "id", JSONPath: "$.order.id"},
+		{Column: "total", JSONPath: "$.order.total"},
+		{Column: "tags", JSONPath: "$.order.tags"},
+		{Column: "missing", JSONPath: "$.order.none"},
+		{Column: "etag", Header: "ETag"},
+		{Column: "ref", Regex: `"ref":"(R-[0-9]+)"`},
+		{Column: "whole", Regex: `R-[0-9]+`},
+		{Column: "big", JSONPath: "$.order.big"},
+		{Column: "items", JSONPath: "$.order.items"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	body := []byte(`{"order":{"id":"ord-7","total":12.50,"tags":["a","b"],"big":9007199254740993,"items":[12345678901234567890]},"ref":"R-42"}`)
+	values := extractor.extract(http.Header{"Etag": {`"v1"`}}, body)
+
+	expected := []string{"ord-7", "12.50", `["a","b"]`, "", `"v1"`, "R-42", "R-42", "9007199254740993", "[12345678901234567890]"}
+	if len(values) != len(expected) {
+		t.Fatalf("Expected %d values, got %v", len(expected), values)
+	}
+	for i := range expected {
+		if values[i] != expected[i] {
+			t.Errorf("Value %d: expected %q, got %q", i, expected[i], values[i])
+		}
+	}
+
+	values = extractor.extract(nil, []byte("not json"))
+	for i, value := range values {
+		if value != "" {
+			t.Errorf("Value %d should be empty for a non matching body, got %q", i, value)
+		}
+	}
+}
+
+func TestNewResponseExtractor_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		conf model.Extraction
+	}{
+		{name: "no column", conf: model.Extraction{JSONPath: "$.id"}},
+		{name: "no source", conf: model.Extraction{Column: "id"}},
+		{name: "two sources", conf: model.Extraction{Column: "id", JSONPath: "$.id", Header: "X-Id"}},
+		{name: "bad regex", conf: model.Extraction{Column: "id", Regex: "("}},
+		{name: "bad path", conf: model.Extraction{Column: "id", JSONPath: "$.a["}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newResponseExtractor([]model.Extraction{tt.conf}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestProcessService_processRecord_Extract(t *testing.T) {
+	status := 201
+	service := &ProcessService{
+		config: model.Config{Extract: []model.Extraction{{Column: "id", JSONPath: "$.id"}}},
+		httpService: &MockHttpService{callFunc: func(record http.Request) ([]byte, int, error) {
+			return []byte(`{"id":"new-1"}`), status, nil
+		}},
+	}
+
+	response, err := service.processRecord(context.Background(), *createTestRequest("https://api.example.com"), 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(response.Extracted) != 1 || response.Extracted[0] != "new-1" {
+		t.Errorf("Expected the extracted id, got %v", response.Extracted)
+	}
+
+	status = 500
+	response, _ = service.processRecord(context.Background(), *createTestRequest("https://api.example.com"), 1)
+	if response.Extracted != nil {
+		t.Errorf("Nothing should be extracted from an error, got %v", response.Extracted)
+	}
+}
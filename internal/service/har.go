@@ -0,0 +1,234 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// harSkippedHeaders are the recorded headers the HTTP client sets itself.
+// Accept-Encoding is left to the client too, so bodies are decoded.
+var harSkippedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Transfer-Encoding": true,
+	"Te":                true,
+	"Upgrade":           true,
+	"Accept-Encoding":   true,
+}
+
+// harEntry holds the fields of a HAR entry needed to replay it.
+type harEntry struct {
+	Request struct {
+		Method   string         `json:"method"`
+		URL      string         `json:"url"`
+		Headers  []harNameValue `json:"headers"`
+		PostData *struct {
+			MimeType string         `json:"mimeType"`
+			Text     string         `json:"text"`
+			Params   []harNameValue `json:"params"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status int `json:"status"`
+	} `json:"response"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harFilter selects the entries to replay.
+type harFilter struct {
+	url      *regexp.Regexp
+	methods  []string
+	statuses []statusRange
+}
+
+func newHARFilter(conf model.HARFilter) (*harFilter, error) {
+	filter := &harFilter{methods: conf.Methods}
+	if conf.URLPattern != "" {
+		pattern, err := regexp.Compile(conf.URLPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid har url_pattern: %w", err)
+		}
+		filter.url = pattern
+	}
+	for _, status := range conf.Status {
+		statuses, err := parseStatusRange(status)
+		if err != nil {
+			return nil, fmt.Errorf("invalid har status: %w", err)
+		}
+		filter.statuses = append(filter.statuses, statuses)
+	}
+	return filter, nil
+}
+
+func (f *harFilter) matches(entry harEntry) bool {
+	if f.url != nil && !f.url.MatchString(entry.Request.URL) {
+		return false
+	}
+	if len(f.methods) > 0 && !containsFold(f.methods, entry.Request.Method) {
+		return false
+	}
+	if len(f.statuses) == 0 {
+		return true
+	}
+	for _, statuses := range f.statuses {
+		if entry.Response.Status >= statuses.min && entry.Response.Status <= statuses.max {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// harRecords streams the requests of the entries of a HAR file that pass
+// the har filter of the config, decoding one entry at a time. The row of
+// a record is the method, the URL and the original response status.
+func (s *ParserService) harRecords(input io.Reader) iter.Seq2[model.Record, error] {
+	return func(yield func(model.Record, error) bool) {
+		if len(s.config.Steps) > 0 {
+			yield(model.Record{}, errors.New("steps are not supported with HAR input"))
+			return
+		}
+		filter, err := newHARFilter(s.config.HAR)
+		if err != nil {
+			yield(model.Record{}, err)
+			return
+		}
+
+		decoder := json.NewDecoder(input)
+		if err := seekJSONArray(decoder, "log", "entries"); err != nil {
+			yield(model.Record{}, fmt.Errorf("error reading HAR file: %w", err))
+			return
+		}
+
+		index := 0
+		for entryIndex := 0; decoder.More(); entryIndex++ {
+			var entry harEntry
+			if err := decoder.Decode(&entry); err != nil {
+				yield(model.Record{}, fmt.Errorf("error reading HAR entry %d: %w", entryIndex, err))
+				return
+			}
+			if !filter.matches(entry) {
+				continue
+			}
+			if !strings.HasPrefix(entry.Request.URL, "http://") && !strings.HasPrefix(entry.Request.URL, "https://") {
+				fmt.Printf("Skipping HAR entry %d: unsupported URL %s\n", entryIndex, entry.Request.URL)
+				continue
+			}
+
+			request, err := s.harRequest(entry)
+			if err != nil {
+				yield(model.Record{}, fmt.Errorf("error creating request for HAR entry %d: %w", entryIndex, err))
+				return
+			}
+			row := []string{entry.Request.Method, entry.Request.URL, strconv.Itoa(entry.Response.Status)}
+			if !yield(model.Record{Index: index, Request: request, Row: row}, nil) {
+				return
+			}
+			index++
+		}
+	}
+}
+
+// harRequest builds the request of an entry with its recorded headers, on
+// top of which the config headers are set.
+func (s *ParserService) harRequest(entry harEntry) (*http.Request, error) {
+	var body string
+	mimeType := ""
+	if postData := entry.Request.PostData; postData != nil {
+		body, mimeType = postData.Text, postData.MimeType
+		if body == "" && len(postData.Params) > 0 {
+			body = encodeHARParams(postData.Params)
+		}
+	}
+
+	request, err := http.NewRequest(entry.Request.Method, entry.Request.URL, bytes.NewBufferString(body))
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range entry.Request.Headers {
+		name := http.CanonicalHeaderKey(header.Name)
+		// HTTP/2 pseudo-headers such as :authority are not real headers
+		if strings.HasPrefix(name, ":") || harSkippedHeaders[name] {
+			continue
+		}
+		request.Header.Add(name, header.Value)
+	}
+	if request.Header.Get("Content-Type") == "" && mimeType != "" && body != "" {
+		request.Header.Set("Content-Type", mimeType)
+	}
+	for name, value := range s.config.Headers {
+		request.Header.Set(name, value)
+	}
+	return request, nil
+}
+
+func encodeHARParams(params []harNameValue) string {
+	encoded := make([]string, len(params))
+	for i, param := range params {
+		encoded[i] = url.QueryEscape(param.Name) + "=" + url.QueryEscape(param.Value)
+	}
+	return strings.Join(encoded, "&")
+}
+
+// seekJSONArray moves the decoder into the array found under the given
+// object keys, so its elements can be decoded one at a time.
+func seekJSONArray(decoder *json.Decoder, keys ...string) error {
+	for _, key := range keys {
+		if err := expectJSONDelim(decoder, '{'); err != nil {
+			return err
+		}
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			if token == json.Delim('}') {
+				return fmt.Errorf("%s not found", key)
+			}
+			if token == key {
+				break
+			}
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return err
+			}
+		}
+	}
+	return expectJSONDelim(decoder, '[')
+}
+
+func expectJSONDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testHAR = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "browser", "version": "1"},
+    "pages": [{"id": "page_1", "title": "{\"entries\": []}"}],
+    "entries": [
+      {
+        "request": {"method": "GET", "url": "https://api.example.com/orders/1", "headers": []},
+        "response": {"status": 200}
+      },
+      {
+        "request": {
+          "method": "POST",
+          "url": "https://api.example.com/orders?draft=false",
+          "headers": [
+            {"name": ":authority", "value": "api.example.com"},
+            {"name": "host", "value": "api.example.com"},
+            {"name": "accept-encoding", "value": "gzip, br"},
+            {"name": "authorization", "value": "Bearer expired"},
+            {"name": "x-request-id", "value": "r-1"},
+            {"name": "x-request-id", "value": "r-2"}
+          ],
+          "postData": {"mimeType": "application/json", "text": "{\"amount\":10}"}
+        },
+        "response": {"status": 502}
+      },
+      {
+        "request": {
+          "method": "post",
+          "url": "https://api.example.com/login",
+          "headers": [],
+          "postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "user", "value": "a b"}, {"name": "next", "value": "/home&x"}]}
+        },
+        "response": {"status": 0}
+      },
+      {
+        "request": {"method": "GET", "url": "data:image/png;base64,AAAA", "headers": []},
+        "response": {"status": 0}
+      },
+      {
+        "request": {"method": "DELETE", "url": "https://api.example.com/orders/2", "headers": []},
+        "response": {"status": 500}
+      }
+    ]
+  }
+}`
+
+func TestParserService_Records_HAR(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "capture.har")
+	if err := os.WriteFile(testFile, []byte(testHAR), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	service := NewParserService(model.Config{
+		Headers: map[string]string{"Authorization": "Bearer fresh"},
+		HAR: model.HARFilter{
+			URLPattern: `^https://api\.example\.com/`,
+			Methods:    []string{"POST", "GET"},
+			Status:     []string{"5xx", "0"},
+		},
+	})
+
+	var records []model.Record
+	for record, err := range service.Records(testFile) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	order := records[0].Request
+	if order.Method != "POST" || order.URL.String() != "https://api.example.com/orders?draft=false" {
+		t.Errorf("Unexpected request %s %s", order.Method, order.URL)
+	}
+	if order.Header.Get("Authorization") != "Bearer fresh" {
+		t.Errorf("Expected the config header to replace the recorded one, got %q", order.Header.Get("Authorization"))
+	}
+	if ids := order.Header.Values("X-Request-Id"); len(ids) != 2 {
+		t.Errorf("Expected the repeated header values, got %v", ids)
+	}
+	for _, name := range []string{":authority", "Host", "Accept-Encoding"} {
+		if _, ok := order.Header[name]; ok {
+			t.Errorf("Header %s should not be replayed", name)
+		}
+	}
+	if order.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the content type of the post data, got %q", order.Header.Get("Content-Type"))
+	}
+	body, _ := io.ReadAll(order.Body)
+	if string(body) != `{"amount":10}` {
+		t.Errorf("Unexpected body %s", body)
+	}
+	if strings.Join(records[0].Row, " ") != "POST https://api.example.com/orders?draft=false 502" {
+		t.Errorf("Unexpected row %v", records[0].Row)
+	}
+
+	login := records[1]
+	body, _ = io.ReadAll(login.Request.Body)
+	if string(body) != "user=a+b&next=%2Fhome%26x" || login.Index != 1 {
+		t.Errorf("Expected the encoded form params, got %s (index %d)", body, login.Index)
+	}
+}
+
+func TestParserService_HasRawRows(t *testing.T) {
+	service := NewParserService(model.Config{})
+	if service.HasRawRows("session.har") {
+		t.Error("HAR entries have no raw row")
+	}
+	if !service.HasRawRows("orders.tsv") || !service.HasRawRows("commands.curl") {
+		t.Error("Delimited rows and curl commands are written back as they are")
+	}
+	if NewParserService(model.Config{InputFormat: "har"}).HasRawRows("export.json") {
+		t.Error("Expected the input_format to be used")
+	}
+}
+
+func TestParserService_Records_HARErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   model.Config
+		content  string
+		expected string
+	}{
+		{name: "no entries", content: `{"log":{"version":"1.2"}}`, expected: "entries not found"},
+		{name: "not a HAR file", content: `[1]`, expected: "error reading HAR file"},
+		{name: "invalid filter", config: model.Config{HAR: model.HARFilter{Status: []string{"abc"}}}, content: testHAR, expected: "invalid har status"},
+		{name: "steps", config: model.Config{Steps: []model.Step{{Name: "a"}}}, content: testHAR, expected: "steps are not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewParserService(tt.config)
+			var err error
+			for _, err = range service.harRecords(strings.NewReader(tt.content)) {
+				if err != nil {
+					break
+				}
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
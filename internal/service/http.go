@@ -2,14 +2,18 @@ package service
 
 import (
 	"batchRequestsRecover/internal/model"
+	"context"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
+	"sync"
 )
 
+// HttpService sends a single request and returns the response body,
+// status code and headers.
 type HttpService interface {
-	call(record http.Request) ([]byte, int, error)
+	call(ctx context.Context, record http.Request) ([]byte, int, http.Header, error)
 }
 
 type HttpServiceMock struct {
@@ -17,9 +21,16 @@ type HttpServiceMock struct {
 	args   model.CommandLineArgs
 }
 
+// HttpServiceReal sends the requests through a single client, shared by all
+// workers so connections are reused. The client is built on first use
+// unless one was provided with WithHttpClient.
 type HttpServiceReal struct {
 	config model.Config
 	args   model.CommandLineArgs
+
+	clientOnce sync.Once
+	client     *http.Client
+	clientErr  error
 }
 
 func createHttpService(config model.Config, args model.CommandLineArgs) HttpService {
@@ -29,7 +40,7 @@ func createHttpService(config model.Config, args model.CommandLineArgs) HttpServ
 	return &HttpServiceReal{config: config, args: args}
 }
 
-func (service *HttpServiceMock) call(record http.Request) ([]byte, int, error) {
+func (service *HttpServiceMock) call(ctx context.Context, record http.Request) ([]byte, int, http.Header, error) {
 	println("--- Start Request ---")
 	println("Dry run, skipping request")
 	println("Request URL: ", record.URL.String())
@@ -40,28 +51,48 @@ func (service *HttpServiceMock) call(record http.Request) ([]byte, int, error) {
 	index := rand.Intn(10)
 
 	if (index % 3) == 0 {
-		return []byte("BadRequest"), 400, nil
+		return []byte("BadRequest"), 400, http.Header{}, nil
 	} else {
-		return []byte("Success"), 200, nil
+		return []byte("Success"), 200, http.Header{}, nil
 	}
 }
 
-func (service *HttpServiceReal) call(record http.Request) ([]byte, int, error) {
-	recClient := loadClient()
-	resp, err := recClient.Do(&record)
+// call sends the request, bounded by the request timeout of the config
+// when one is set. The body is read within the same deadline.
+func (service *HttpServiceReal) call(ctx context.Context, record http.Request) ([]byte, int, http.Header, error) {
+	if timeout := millis(service.config.Timeouts.RequestMillis); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	client, err := service.httpClient()
 	if err != nil {
-		return nil, 0, fmt.Errorf("error making request: %w", err)
+		return nil, 0, nil, fmt.Errorf("error loading client: %w", err)
+	}
+	resp, err := client.Do(record.WithContext(ctx))
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("error making request: %w", err)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error reading response: %w", err)
+		return nil, 0, nil, fmt.Errorf("error reading response: %w", err)
 	}
 	err = resp.Body.Close()
 	if err != nil {
-		return nil, 0, fmt.Errorf("error closing response body: %w", err)
+		return nil, 0, nil, fmt.Errorf("error closing response body: %w", err)
 	}
 	fmt.Println("Status:", resp.Status)
 
-	return body, resp.StatusCode, nil
+	return body, resp.StatusCode, resp.Header, nil
+}
+
+func (service *HttpServiceReal) httpClient() (*http.Client, error) {
+	service.clientOnce.Do(func() {
+		if service.client == nil {
+			service.client, service.clientErr = NewHttpClient(service.config)
+		}
+	})
+	return service.client, service.clientErr
 }
@@ -2,11 +2,16 @@ package service
 
 import (
 	"batchRequestsRecover/internal/model"
+	"context"
+	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestCreateHttpService(t *testing.T) {
@@ -106,7 +111,7 @@ func TestHttpServiceMock_call(t *testing.T) {
 				t.Fatalf("Failed to create request: %v", err)
 			}
 
-			body, status, err := mockService.call(*req)
+			body, status, _, err := mockService.call(context.Background(), *req)
 
 			if err != nil {
 				t.Errorf("Unexpected error: %v", err)
@@ -157,7 +162,7 @@ func TestHttpServiceMock_call_VerifyRequestLogging(t *testing.T) {
 	req, _ := http.NewRequest("POST", testURL, nil)
 
 	// Call the service
-	body, status, err := mockService.call(*req)
+	body, status, _, err := mockService.call(context.Background(), *req)
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -212,7 +217,7 @@ func TestHttpServiceReal_call_Success(t *testing.T) {
 		t.Fatalf("Failed to create request: %v", err)
 	}
 
-	body, status, err := realService.call(*req)
+	body, status, _, err := realService.call(context.Background(), *req)
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -228,6 +233,36 @@ func TestHttpServiceReal_call_Success(t *testing.T) {
 	}
 }
 
+func TestHttpServiceReal_call_ReturnsHeaders(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer testServer.Close()
+
+	realService := &HttpServiceReal{
+		config: model.Config{ApiEndpoint: testServer.URL, Method: "GET"},
+		args:   model.CommandLineArgs{DryRun: false},
+	}
+
+	req, err := http.NewRequest("GET", testServer.URL+"/test", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	_, status, header, err := realService.call(context.Background(), *req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if status != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", status)
+	}
+	if header.Get("Retry-After") != "30" {
+		t.Errorf("Expected Retry-After header 30, got %q", header.Get("Retry-After"))
+	}
+}
+
 func TestHttpServiceReal_call_ErrorStatuses(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -299,7 +334,7 @@ func TestHttpServiceReal_call_ErrorStatuses(t *testing.T) {
 				t.Fatalf("Failed to create request: %v", err)
 			}
 
-			body, status, err := realService.call(*req)
+			body, status, _, err := realService.call(context.Background(), *req)
 
 			if err != nil {
 				t.Fatalf("Unexpected error: %v", err)
@@ -360,7 +395,7 @@ func TestHttpServiceReal_call_WithHeaders(t *testing.T) {
 		req.Header.Add(key, value)
 	}
 
-	body, status, err := realService.call(*req)
+	body, status, _, err := realService.call(context.Background(), *req)
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -412,7 +447,7 @@ func TestHttpServiceReal_call_WithRequestBody(t *testing.T) {
 		t.Fatalf("Failed to create request: %v", err)
 	}
 
-	body, status, err := realService.call(*req)
+	body, status, _, err := realService.call(context.Background(), *req)
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -447,7 +482,7 @@ func TestHttpServiceReal_call_NetworkError(t *testing.T) {
 		t.Fatalf("Failed to create request: %v", err)
 	}
 
-	body, status, err := realService.call(*req)
+	body, status, _, err := realService.call(context.Background(), *req)
 
 	if err == nil {
 		t.Error("Expected error for invalid URL, got none")
@@ -494,7 +529,7 @@ func TestHttpServiceReal_call_LargeResponse(t *testing.T) {
 		t.Fatalf("Failed to create request: %v", err)
 	}
 
-	body, status, err := realService.call(*req)
+	body, status, _, err := realService.call(context.Background(), *req)
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -513,11 +548,14 @@ func TestHttpServiceReal_call_LargeResponse(t *testing.T) {
 	}
 }
 
-func TestLoadClient_Configuration(t *testing.T) {
-	client := loadClient()
+func TestNewHttpClient_Configuration(t *testing.T) {
+	client, err := NewHttpClient(model.Config{})
+	if err != nil {
+		t.Fatalf("NewHttpClient failed: %v", err)
+	}
 
 	if client == nil {
-		t.Fatal("loadClient returned nil")
+		t.Fatal("NewHttpClient returned nil")
 	}
 
 	if client.Transport == nil {
@@ -533,8 +571,172 @@ func TestLoadClient_Configuration(t *testing.T) {
 		t.Fatal("TLS config should not be nil")
 	}
 
-	if !transport.TLSClientConfig.InsecureSkipVerify {
-		t.Error("Expected InsecureSkipVerify to be true")
+	if transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("Expected InsecureSkipVerify to be false by default")
+	}
+}
+
+func TestNewHttpClient_Timeouts(t *testing.T) {
+	client, err := NewHttpClient(model.Config{Timeouts: model.Timeouts{
+		ConnectMillis:        1000,
+		TLSHandshakeMillis:   2000,
+		ResponseHeaderMillis: 3000,
+	}})
+	if err != nil {
+		t.Fatalf("NewHttpClient failed: %v", err)
+	}
+
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatal("Transport is not *http.Transport")
+	}
+
+	if transport.TLSHandshakeTimeout != 2*time.Second {
+		t.Errorf("Expected TLS handshake timeout 2s, got %v", transport.TLSHandshakeTimeout)
+	}
+	if transport.ResponseHeaderTimeout != 3*time.Second {
+		t.Errorf("Expected response header timeout 3s, got %v", transport.ResponseHeaderTimeout)
+	}
+	if transport.DialContext == nil {
+		t.Error("Expected a dialer with the connect timeout")
+	}
+}
+
+func TestNewHttpClient_Transport(t *testing.T) {
+	tests := []struct {
+		name             string
+		transport        model.Transport
+		wantIdlePerHost  int
+		wantIdleTimeout  time.Duration
+		wantHTTP2        bool
+		wantNoKeepAlives bool
+	}{
+		{
+			name:            "defaults",
+			transport:       model.Transport{},
+			wantIdlePerHost: 100,
+			wantIdleTimeout: 90 * time.Second,
+			wantHTTP2:       true,
+		},
+		{
+			name: "configured",
+			transport: model.Transport{
+				MaxIdleConnsPerHost: 8,
+				IdleTimeoutMillis:   5000,
+				DisableHTTP2:        true,
+				DisableKeepAlives:   true,
+			},
+			wantIdlePerHost:  8,
+			wantIdleTimeout:  5 * time.Second,
+			wantHTTP2:        false,
+			wantNoKeepAlives: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHttpClient(model.Config{Transport: tt.transport})
+			if err != nil {
+				t.Fatalf("NewHttpClient failed: %v", err)
+			}
+			transport := client.Transport.(*http.Transport)
+
+			if transport.MaxIdleConnsPerHost != tt.wantIdlePerHost {
+				t.Errorf("Expected %d idle connections per host, got %d", tt.wantIdlePerHost, transport.MaxIdleConnsPerHost)
+			}
+			if transport.IdleConnTimeout != tt.wantIdleTimeout {
+				t.Errorf("Expected idle timeout %v, got %v", tt.wantIdleTimeout, transport.IdleConnTimeout)
+			}
+			if transport.ForceAttemptHTTP2 != tt.wantHTTP2 {
+				t.Errorf("Expected ForceAttemptHTTP2 %v, got %v", tt.wantHTTP2, transport.ForceAttemptHTTP2)
+			}
+			if !tt.wantHTTP2 && transport.TLSNextProto == nil {
+				t.Error("Expected an empty TLSNextProto to disable HTTP/2")
+			}
+			if transport.DisableKeepAlives != tt.wantNoKeepAlives {
+				t.Errorf("Expected DisableKeepAlives %v, got %v", tt.wantNoKeepAlives, transport.DisableKeepAlives)
+			}
+		})
+	}
+}
+
+func TestHttpServiceReal_call_ReusesConnections(t *testing.T) {
+	var mu sync.Mutex
+	newConns := 0
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	testServer.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			newConns++
+			mu.Unlock()
+		}
+	}
+	testServer.Start()
+	defer testServer.Close()
+
+	realService := &HttpServiceReal{config: model.Config{}, args: model.CommandLineArgs{DryRun: false}}
+
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest("GET", testServer.URL, nil)
+		if _, _, _, err := realService.call(context.Background(), *req); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if newConns != 1 {
+		t.Errorf("Expected a single reused connection, got %d", newConns)
+	}
+}
+
+func TestHttpServiceReal_call_RequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer testServer.Close()
+	defer close(release)
+
+	realService := &HttpServiceReal{
+		config: model.Config{Timeouts: model.Timeouts{RequestMillis: 50}},
+		args:   model.CommandLineArgs{DryRun: false},
+	}
+
+	req, _ := http.NewRequest("GET", testServer.URL+"/slow", nil)
+
+	start := time.Now()
+	_, _, _, err := realService.call(context.Background(), *req)
+	if err == nil {
+		t.Fatal("Expected a timeout error")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Request should time out after ~50ms, took %v", elapsed)
+	}
+}
+
+func TestHttpServiceReal_call_ContextCancelled(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	realService := &HttpServiceReal{config: model.Config{}, args: model.CommandLineArgs{DryRun: false}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req, _ := http.NewRequest("GET", testServer.URL, nil)
+	if _, _, _, err := realService.call(ctx, *req); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context canceled, got %v", err)
 	}
 }
 
@@ -563,7 +765,7 @@ func TestHttpServiceReal_call_EmptyResponse(t *testing.T) {
 		t.Fatalf("Failed to create request: %v", err)
 	}
 
-	body, status, err := realService.call(*req)
+	body, status, _, err := realService.call(context.Background(), *req)
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
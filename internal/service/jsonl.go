@@ -0,0 +1,119 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+)
+
+// defaultJSONLBodyField is the field holding the body of JSON Lines input
+// when the config sets no body_column.
+const defaultJSONLBodyField = "body"
+
+// jsonlRecords streams the requests of a JSON Lines input, one object per
+// line. The fields of each line are bound like the columns of a header row,
+// so the config looks them up by name.
+func (s *ParserService) jsonlRecords(input io.Reader) iter.Seq2[model.Record, error] {
+	return func(yield func(model.Record, error) bool) {
+		if s.config.BodyColumn == "" {
+			s.config.BodyColumn = defaultJSONLBodyField
+		}
+		reader := bufio.NewReader(input)
+
+		index, line := 0, 0
+		for {
+			raw, err := reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				yield(model.Record{}, fmt.Errorf("error reading line: %w", err))
+				return
+			}
+			if len(raw) == 0 && err == io.EOF {
+				return
+			}
+			line++
+
+			if len(bytes.TrimSpace(raw)) == 0 {
+				fmt.Println("Skipping empty row")
+				continue
+			}
+
+			names, row, parseErr := parseJSONLine(raw)
+			if parseErr != nil {
+				yield(model.Record{}, fmt.Errorf("error reading line %d: %w", line, parseErr))
+				return
+			}
+			if bindErr := s.config.BindHeader(names); bindErr != nil {
+				yield(model.Record{}, fmt.Errorf("error reading line %d: %w", line, bindErr))
+				return
+			}
+
+			record, recordErr := s.newRecord(index, row, raw)
+			if recordErr != nil {
+				yield(model.Record{}, fmt.Errorf("line %d: %w", line, recordErr))
+				return
+			}
+			if !yield(record, nil) {
+				return
+			}
+			index++
+		}
+	}
+}
+
+// parseJSONLine returns the field names of a JSON object, sorted, along
+// with their values. Nested objects are also flattened into "parent.child"
+// fields.
+func parseJSONLine(line []byte) ([]string, []string, error) {
+	fields := make(map[string]string)
+	if err := flattenJSONObject("", line, fields); err != nil {
+		return nil, nil, err
+	}
+	names := slices.Sorted(maps.Keys(fields))
+	row := make([]string, len(names))
+	for i, name := range names {
+		row[i] = fields[name]
+	}
+	return names, row, nil
+}
+
+func flattenJSONObject(prefix string, data []byte, fields map[string]string) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("invalid JSON object: %w", err)
+	}
+	if object == nil {
+		return errors.New("invalid JSON object: null")
+	}
+	for name, value := range object {
+		fields[prefix+name] = jsonFieldValue(value)
+		if trimmed := bytes.TrimSpace(value); len(trimmed) > 0 && trimmed[0] == '{' {
+			if err := flattenJSONObject(prefix+name+".", trimmed, fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonFieldValue returns strings unquoted, null as an empty string and any
+// other value as the JSON text of the line.
+func jsonFieldValue(value json.RawMessage) string {
+	trimmed := bytes.TrimSpace(value)
+	switch {
+	case len(trimmed) == 0 || string(trimmed) == "null":
+		return ""
+	case trimmed[0] == '"':
+		var text string
+		if err := json.Unmarshal(trimmed, &text); err == nil {
+			return text
+		}
+	}
+	return string(trimmed)
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParserService_Records_JSONL(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "requests.jsonl")
+	content := `{"user":{"id":"u 1","tier":2},"status":"active","trace":"t-1","body":{"amount":12.50,"tags":["a"]}}` + "\n" +
+		"\n" +
+		`{"user":{"id":"u2","tier":3},"status":null,"trace":"","body":"plain text"}`
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	service := NewParserService(model.Config{
+		ApiEndpoint:  "https://api.example.com/users/{user.id}",
+		Method:       "POST",
+		PathVars:     []string{"user.id"},
+		QueryVars:    []string{"status"},
+		HeaderFields: map[string]string{"X-Trace-Id": "trace"},
+		HasBody:      true,
+	})
+
+	var records []model.Record
+	for record, err := range service.Records(testFile) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	first := records[0].Request
+	if first.URL.String() != "https://api.example.com/users/u%201?status=active" {
+		t.Errorf("Unexpected URL %s", first.URL)
+	}
+	if first.Header.Get("X-Trace-Id") != "t-1" {
+		t.Errorf("Expected the header field, got %v", first.Header)
+	}
+	body, _ := io.ReadAll(first.Body)
+	if string(body) != `{"amount":12.50,"tags":["a"]}` {
+		t.Errorf("Expected the nested object as written, got %s", body)
+	}
+	if !strings.HasSuffix(string(records[0].Raw), "}\n") || records[1].Index != 1 {
+		t.Errorf("Unexpected raw line %q or index %d", records[0].Raw, records[1].Index)
+	}
+
+	second := records[1].Request
+	if second.URL.String() != "https://api.example.com/users/u2?status=" {
+		t.Errorf("Unexpected URL %s", second.URL)
+	}
+	if _, ok := second.Header["X-Trace-Id"]; ok {
+		t.Error("Empty header fields must not be sent")
+	}
+	body, _ = io.ReadAll(second.Body)
+	if string(body) != "plain text" {
+		t.Errorf("Expected the string body, got %s", body)
+	}
+}
+
+func TestParserService_Records_JSONLErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "invalid JSON", content: "{\"id\":\"1\"}\n{\"id\":\n", expected: "line 2: invalid JSON object"},
+		{name: "not an object", content: "[1,2]\n", expected: "line 1: invalid JSON object"},
+		{name: "missing field", content: "{\"other\":\"1\"}\n", expected: "line 1: missing columns in the header: id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewParserService(model.Config{
+				ApiEndpoint: "https://api.example.com/{id}",
+				PathVars:    []string{"id"},
+			})
+			var err error
+			for _, err = range service.jsonlRecords(strings.NewReader(tt.content)) {
+				if err != nil {
+					break
+				}
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestParserService_inputFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		path     string
+		expected string
+	}{
+		{path: "input.tsv", expected: InputFormatCSV},
+		{path: "input.JSONL", expected: InputFormatJSONL},
+		{path: "input.ndjson", expected: InputFormatJSONL},
+		{format: "jsonl", path: "input.txt", expected: InputFormatJSONL},
+		{format: "CSV", path: "input.jsonl", expected: InputFormatCSV},
+		{path: "capture.har", expected: InputFormatHAR},
+		{format: "har", path: "capture.json", expected: InputFormatHAR},
+		{path: "commands.curl", expected: InputFormatCurl},
+	}
+
+	for _, tt := range tests {
+		service := NewParserService(model.Config{InputFormat: tt.format})
+		format, err := service.inputFormat(tt.path)
+		if err != nil || format != tt.expected {
+			t.Errorf("inputFormat(%q, %q): expected %s, got %s (err %v)", tt.format, tt.path, tt.expected, format, err)
+		}
+	}
+
+	if _, err := NewParserService(model.Config{InputFormat: "xml"}).inputFormat("input.xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled JSONPath expression. Only the subset needed to
+// address a single value is supported: the root "$" (optional), child
+// members as ".name" or "['name']" and array elements as "[n]", where a
+// negative n counts from the end.
+type jsonPath []jsonPathStep
+
+type jsonPathStep struct {
+	key   string
+	index int
+	isKey bool
+}
+
+func parseJSONPath(expr string) (jsonPath, error) {
+	rest := strings.TrimSpace(expr)
+	rest = strings.TrimPrefix(rest, "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	path := jsonPath{}
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("invalid json path %q: empty member name", expr)
+			}
+			path = append(path, jsonPathStep{key: name, isKey: true})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid json path %q: missing ]", expr)
+			}
+			inner := rest[1:end]
+			if quoted, ok := unquotePathKey(inner); ok {
+				path = append(path, jsonPathStep{key: quoted, isKey: true})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid json path %q: bad index %q", expr, inner)
+				}
+				path = append(path, jsonPathStep{index: index})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid json path %q", expr)
+		}
+	}
+	return path, nil
+}
+
+func unquotePathKey(value string) (string, bool) {
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1], true
+	}
+	return "", false
+}
+
+// lookup returns the value addressed by the path in a document decoded
+// with encoding/json, and whether it exists.
+func (p jsonPath) lookup(doc any) (any, bool) {
+	current := doc
+	for _, step := range p {
+		if step.isKey {
+			object, ok := current.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if current, ok = object[step.key]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		array, ok := current.([]any)
+		if !ok {
+			return nil, false
+		}
+		index := step.index
+		if index < 0 {
+			index += len(array)
+		}
+		if index < 0 || index >= len(array) {
+			return nil, false
+		}
+		current = array[index]
+	}
+	return current, true
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONPath_Lookup(t *testing.T) {
+	var doc any
+	body := `{"status":"OK","data":{"items":[{"id":1},{"id":2}],"dotted.key":true},"empty":null}`
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatalf("Invalid test document: %v", err)
+	}
+
+	tests := []struct {
+		expr     string
+		expected any
+		found    bool
+	}{
+		{expr: "$.status", expected: "OK", found: true},
+		{expr: "status", expected: "OK", found: true},
+		{expr: "$.data.items[1].id", expected: float64(2), found: true},
+		{expr: "$.data.items[-1].id", expected: float64(2), found: true},
+		{expr: "$['data']['dotted.key']", expected: true, found: true},
+		{expr: "$.empty", expected: nil, found: true},
+		{expr: "$.data.items[5]", found: false},
+		{expr: "$.missing", found: false},
+		{expr: "$.status.inner", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			path, err := parseJSONPath(tt.expr)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			value, found := path.lookup(doc)
+			if found != tt.found {
+				t.Fatalf("Expected found %v, got %v", tt.found, found)
+			}
+			if found && value != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestParseJSONPath_Invalid(t *testing.T) {
+	for _, expr := range []string{"$.a[", "$.a[x]", "$..a", "$.a.[0]x"} {
+		if _, err := parseJSONPath(expr); err == nil {
+			t.Errorf("Expected an error for %q", expr)
+		}
+	}
+}
@@ -7,75 +7,305 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
+	"iter"
+	"maps"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 )
 
 type ParserService struct {
 	config model.Config
+
+	// the request templates are compiled once, on first use
+	templateOnce sync.Once
+	templates    *requestTemplate
+	templateErr  error
 }
 
 func NewParserService(config model.Config) *ParserService {
 	return &ParserService{config: config}
 }
 
+// ReadAndParse reads the whole file and returns all its requests.
+// Prefer Records for big files, it never holds more than one row in memory.
 func (s *ParserService) ReadAndParse(filePath string) ([]http.Request, error) {
-	content, err := s.readFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("error reading file: %w", err)
-	}
-	return s.parse(content)
+	return collectRequests(s.Records(filePath))
 }
 
-func (s *ParserService) readFile(filePath string) ([]byte, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening file: %w", err)
-	}
-	defer file.Close()
+// Records streams the requests of the file one row at a time. Iteration
+// stops at the first error, which is yielded with an empty record.
+func (s *ParserService) Records(filePath string) iter.Seq2[model.Record, error] {
+	return func(yield func(model.Record, error) bool) {
+		format, err := s.inputFormat(filePath)
+		if err != nil {
+			yield(model.Record{}, err)
+			return
+		}
+		if format == InputFormatXLSX {
+			for record, err := range s.rowRecords(s.xlsxRows(filePath)) {
+				if !yield(record, err) {
+					return
+				}
+			}
+			return
+		}
 
-	content, err := io.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("error reading file: %w", err)
+		file, err := os.Open(filePath)
+		if err != nil {
+			yield(model.Record{}, fmt.Errorf("error opening file: %w", err))
+			return
+		}
+		defer file.Close()
+
+		input, err := util.DecodeInput(file, s.config.InputEncoding)
+		if err != nil {
+			yield(model.Record{}, err)
+			return
+		}
+		records := s.records
+		switch format {
+		case InputFormatJSONL:
+			records = s.jsonlRecords
+		case InputFormatHAR:
+			records = s.harRecords
+		case InputFormatCurl:
+			records = s.curlRecords
+		}
+		for record, err := range records(input) {
+			if !yield(record, err) {
+				return
+			}
+		}
 	}
+}
 
-	return util.RemoveBOM(content), nil
+// Input formats of the file read by the parser
+const (
+	InputFormatCSV   = "csv"
+	InputFormatJSONL = "jsonl"
+	InputFormatXLSX  = "xlsx"
+	InputFormatHAR   = "har"
+	InputFormatCurl  = "curl"
+)
 
+// inputFormat returns the format set in the config or, when it is empty,
+// the one of the file extension, csv for unknown extensions.
+func (s *ParserService) inputFormat(filePath string) (string, error) {
+	format := strings.ToLower(s.config.InputFormat)
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(filePath)) {
+		case ".jsonl", ".ndjson":
+			return InputFormatJSONL, nil
+		case ".xlsx":
+			return InputFormatXLSX, nil
+		case ".har":
+			return InputFormatHAR, nil
+		case ".curl":
+			return InputFormatCurl, nil
+		default:
+			return InputFormatCSV, nil
+		}
+	}
+	switch format {
+	case InputFormatCSV, "tsv":
+		return InputFormatCSV, nil
+	case InputFormatJSONL, "ndjson":
+		return InputFormatJSONL, nil
+	case InputFormatXLSX, InputFormatHAR, InputFormatCurl:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown input_format %q", s.config.InputFormat)
 }
 
-func (s *ParserService) parse(content []byte) ([]http.Request, error) {
+// HasRawRows reports whether the records of the file carry the raw input
+// row written to the failed rows file. The entries of a HAR file do not.
+func (s *ParserService) HasRawRows(filePath string) bool {
+	format, err := s.inputFormat(filePath)
+	return err != nil || format != InputFormatHAR
+}
 
-	reader := s.getReader(bytes.NewReader(content))
+// Header returns the header row of the file, with its fields and its text
+// exactly as written, or an empty record when the config does not use a
+// header row.
+func (s *ParserService) Header(filePath string) (model.Record, error) {
+	format, err := s.inputFormat(filePath)
+	if err != nil || (format != InputFormatCSV && format != InputFormatXLSX) || !s.config.HasHeader {
+		return model.Record{}, err
+	}
 
-	var records []http.Request
+	rows := s.xlsxRows(filePath)
+	if format == InputFormatCSV {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return model.Record{}, fmt.Errorf("error opening file: %w", err)
+		}
+		defer file.Close()
+		input, err := util.DecodeInput(file, s.config.InputEncoding)
+		if err != nil {
+			return model.Record{}, err
+		}
+		rows = s.csvRows(input)
+	}
 
-	for {
-		row, err := reader.Read()
-		if err == io.EOF {
-			break
+	for row, err := range rows {
+		if err != nil {
+			return model.Record{}, fmt.Errorf("error reading header: %w", err)
 		}
-		if err != nil && len(row) == 0 {
-			return nil, fmt.Errorf("error reading row: %w", err)
+		if !s.isAEmptyRow(row.fields) {
+			return model.Record{Row: row.fields, Raw: row.raw}, nil
 		}
+	}
+	return model.Record{}, nil
+}
 
-		if s.isAEmptyRow(row) {
-			fmt.Println("Skipping empty row")
-			continue
+func (s *ParserService) records(input io.Reader) iter.Seq2[model.Record, error] {
+	return s.rowRecords(s.csvRows(input))
+}
+
+// inputRow is a row of a delimited file or a workbook, with its text as
+// written in a delimited file.
+type inputRow struct {
+	fields []string
+	raw    []byte
+}
+
+// csvRows streams the rows of a delimited file.
+func (s *ParserService) csvRows(input io.Reader) iter.Seq2[inputRow, error] {
+	return func(yield func(inputRow, error) bool) {
+		recorder := &rowRecorder{input: input}
+		reader := s.getReader(recorder)
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil && len(row) == 0 {
+				yield(inputRow{}, err)
+				return
+			}
+			if !yield(inputRow{fields: row, raw: recorder.take(reader.InputOffset())}, nil) {
+				return
+			}
 		}
+	}
+}
 
-		request, err := s.createRequest(row)
-		if err != nil {
-			return records, fmt.Errorf("error creating request: %w", err)
+// rowRecords turns the rows into records, skipping the empty ones and
+// binding the first one as the header when the config has a header row.
+func (s *ParserService) rowRecords(rows iter.Seq2[inputRow, error]) iter.Seq2[model.Record, error] {
+	return func(yield func(model.Record, error) bool) {
+		index := 0
+		headerBound := !s.config.HasHeader
+		for row, err := range rows {
+			if err != nil {
+				yield(model.Record{}, fmt.Errorf("error reading row: %w", err))
+				return
+			}
+
+			if s.isAEmptyRow(row.fields) {
+				fmt.Println("Skipping empty row")
+				continue
+			}
+
+			if !headerBound {
+				if err := s.config.BindHeader(row.fields); err != nil {
+					yield(model.Record{}, fmt.Errorf("error reading header: %w", err))
+					return
+				}
+				headerBound = true
+				continue
+			}
+
+			record, err := s.newRecord(index, row.fields, row.raw)
+			if err != nil {
+				yield(model.Record{}, err)
+				return
+			}
+			if !yield(record, nil) {
+				return
+			}
+			index++
 		}
-		records = append(records, *request)
 	}
+}
+
+// newRecord builds the record of a row, with its request unless the
+// config has steps, which build their requests when the row is processed.
+func (s *ParserService) newRecord(index int, row []string, raw []byte) (model.Record, error) {
+	record := model.Record{Index: index, Row: row, Raw: raw}
+	if len(s.config.Steps) > 0 {
+		record.Values = s.config.RowValues(row)
+		return record, nil
+	}
+	request, err := s.createRequest(row)
+	if err != nil {
+		return model.Record{}, fmt.Errorf("error creating request: %w", err)
+	}
+	record.Request = request
+	return record, nil
+}
+
+// rowRecorder keeps the bytes read by the csv reader until they are taken,
+// so the raw text of every row can be recovered from the reader offsets.
+type rowRecorder struct {
+	input  io.Reader
+	buf    []byte
+	offset int64
+}
+
+func (r *rowRecorder) Read(p []byte) (int, error) {
+	n, err := r.input.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
 
-	return records, nil
+// take returns the bytes up to the end offset, without the blank lines
+// the csv reader skipped before the row, and forgets them.
+func (r *rowRecorder) take(end int64) []byte {
+	n := int(end - r.offset)
+	raw := bytes.TrimLeft(r.buf[:n], "\r\n")
+	raw = bytes.Clone(raw)
+	r.buf = append(r.buf[:0], r.buf[n:]...)
+	r.offset = end
+	return raw
+}
+
+// collectRequests drains the records, returning the requests read
+// before the first error along with the error. Records of steps carry
+// no request and are left out.
+func collectRequests(records iter.Seq2[model.Record, error]) ([]http.Request, error) {
+	var requests []http.Request
+	for record, err := range records {
+		if err != nil {
+			return requests, err
+		}
+		if record.Request != nil {
+			requests = append(requests, *record.Request)
+		}
+	}
+	return requests, nil
 }
 
 func (s *ParserService) createRequest(row []string) (*http.Request, error) {
-	reqUrl, err := s.config.WithPathVars(row)
+	templates, err := s.requestTemplate()
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]string
+	if templates.active() {
+		values = s.config.RowValues(row)
+	}
+
+	endpoint := s.config.ApiEndpoint
+	if templates.endpoint != nil {
+		if endpoint, err = renderTemplate(templates.endpoint, values); err != nil {
+			return nil, err
+		}
+	}
+	reqUrl, err := s.config.ReplacePathVars(endpoint, row)
 	if err != nil {
 		return nil, fmt.Errorf("error getting path vars: %w", err)
 	}
@@ -84,16 +314,39 @@ func (s *ParserService) createRequest(row []string) (*http.Request, error) {
 		return nil, fmt.Errorf("error getting query vars: %w", err)
 	}
 
-	reqUrl += queryVars
+	reqUrl = model.AppendQuery(reqUrl, queryVars)
+
+	headerFields, err := s.config.GetHeaderFields(row)
+	if err != nil {
+		return nil, fmt.Errorf("error getting header fields: %w", err)
+	}
+	headers := s.config.Headers
+	if len(templates.headers) > 0 || len(headerFields) > 0 {
+		headers = maps.Clone(s.config.Headers)
+		if headers == nil {
+			headers = make(map[string]string, len(headerFields))
+		}
+		maps.Copy(headers, headerFields)
+		for name, header := range templates.headers {
+			if headers[name], err = renderTemplate(header, values); err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	var body string
-	if s.config.HasBody {
-		body = row[len(s.config.PathVars)+len(s.config.QueryVars)]
+	if templates.body != nil {
+		body, err = renderTemplate(templates.body, values)
+	} else {
+		body, err = s.config.GetBody(row)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting body: %w", err)
 	}
 
 	csvReq := model.NewCsvRequest(
 		model.WithMethod(s.config.Method),
-		model.WithHeaders(s.config.Headers),
+		model.WithHeaders(headers),
 		model.WithBody(body),
 		model.WithRequestUrl(reqUrl),
 	)
@@ -104,6 +357,14 @@ func (s *ParserService) createRequest(row []string) (*http.Request, error) {
 	return request, nil
 }
 
+// requestTemplate compiles the templates of the config.
+func (s *ParserService) requestTemplate() (*requestTemplate, error) {
+	s.templateOnce.Do(func() {
+		s.templates, s.templateErr = newRequestTemplate(s.config)
+	})
+	return s.templates, s.templateErr
+}
+
 func (s *ParserService) isAEmptyRow(row []string) bool {
 	return len(row) == 0 || (len(row) == 1 && strings.TrimSpace(row[0]) == "")
 }
@@ -121,13 +382,16 @@ func createHttpRequest(csvReq *model.CsvRequest) (*http.Request, error) {
 
 func (s *ParserService) getReader(file io.Reader) *csv.Reader {
 	reader := csv.NewReader(file)
-	// Set delimiter (default to tab if not specified)
-	if s.config.CSVDelimiter != "" && len(s.config.CSVDelimiter) > 0 {
-		reader.Comma = rune(s.config.CSVDelimiter[0])
-	} else {
-		reader.Comma = '\t' // Tab separator
-	}
+	reader.Comma = s.delimiter()
 	reader.LazyQuotes = true       // Allow lazy quotes
 	reader.TrimLeadingSpace = true // Trim leading space
 	return reader
 }
+
+// delimiter returns the field delimiter of the config, tab when not specified.
+func (s *ParserService) delimiter() rune {
+	if s.config.CSVDelimiter != "" && len(s.config.CSVDelimiter) > 0 {
+		return rune(s.config.CSVDelimiter[0])
+	}
+	return '\t' // Tab separator
+}
@@ -3,23 +3,56 @@ package service
 import (
 	"batchRequestsRecover/internal/model"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"iter"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // MockHttpService is a test mock for HttpService
 type MockHttpService struct {
-	callFunc func(record http.Request) ([]byte, int, error)
+	callFunc   func(record http.Request) ([]byte, int, error)
+	headerFunc func(record http.Request) http.Header
 }
 
-func (m *MockHttpService) call(record http.Request) ([]byte, int, error) {
+func (m *MockHttpService) call(ctx context.Context, record http.Request) ([]byte, int, http.Header, error) {
+	body, status, err := []byte("default response"), 200, error(nil)
 	if m.callFunc != nil {
-		return m.callFunc(record)
+		body, status, err = m.callFunc(record)
 	}
-	return []byte("default response"), 200, nil
+	header := http.Header{}
+	if m.headerFunc != nil {
+		header = m.headerFunc(record)
+	}
+	return body, status, header, err
+}
+
+// memoryWriter collects the responses written by ProcessAll
+type memoryWriter struct {
+	respList  []string
+	errList   []string
+	indexes   []int
+	responses []model.Response
+}
+
+func (w *memoryWriter) Write(index int, response model.Response) error {
+	w.indexes = append(w.indexes, index)
+	w.responses = append(w.responses, response)
+	if response.Type == model.SUCCESS {
+		w.respList = append(w.respList, response.Message)
+	} else {
+		w.errList = append(w.errList, response.Message)
+	}
+	return nil
 }
 
 func TestNewProcessService(t *testing.T) {
@@ -65,7 +98,7 @@ func TestProcessService_processRecord_Success(t *testing.T) {
 			responseBody: []byte(`{"status":"ok"}`),
 			statusCode:   200,
 			expectedType: model.SUCCESS,
-			expectedMsg:  `0-200 - {"status":"ok"}`,
+			expectedMsg:  `0-200 [attempts=1] - {"status":"ok"}`,
 			mockError:    nil,
 		},
 		{
@@ -73,7 +106,7 @@ func TestProcessService_processRecord_Success(t *testing.T) {
 			responseBody: []byte(`{"id":"123"}`),
 			statusCode:   201,
 			expectedType: model.SUCCESS,
-			expectedMsg:  `5-201 - {"id":"123"}`,
+			expectedMsg:  `5-201 [attempts=1] - {"id":"123"}`,
 			mockError:    nil,
 		},
 		{
@@ -81,7 +114,7 @@ func TestProcessService_processRecord_Success(t *testing.T) {
 			responseBody: []byte("Bad Request"),
 			statusCode:   400,
 			expectedType: model.ERROR,
-			expectedMsg:  "0-400 - Bad Request",
+			expectedMsg:  "0-400 [attempts=1] - Bad Request",
 			mockError:    nil,
 		},
 		{
@@ -89,7 +122,7 @@ func TestProcessService_processRecord_Success(t *testing.T) {
 			responseBody: []byte("Internal Server Error"),
 			statusCode:   500,
 			expectedType: model.ERROR,
-			expectedMsg:  "1-500 - Internal Server Error",
+			expectedMsg:  "1-500 [attempts=1] - Internal Server Error",
 			mockError:    nil,
 		},
 		{
@@ -97,7 +130,7 @@ func TestProcessService_processRecord_Success(t *testing.T) {
 			responseBody: []byte("Not Found"),
 			statusCode:   404,
 			expectedType: model.ERROR,
-			expectedMsg:  "2-404 - Not Found",
+			expectedMsg:  "2-404 [attempts=1] - Not Found",
 			mockError:    nil,
 		},
 	}
@@ -128,7 +161,7 @@ func TestProcessService_processRecord_Success(t *testing.T) {
 				index = 0
 			}
 
-			response, err := service.processRecord(*req, index)
+			response, err := service.processRecord(context.Background(), *req, index)
 
 			if tt.mockError != nil {
 				if err == nil {
@@ -165,14 +198,18 @@ func TestProcessService_processRecord_Error(t *testing.T) {
 	}
 
 	req, _ := http.NewRequest("GET", "https://api.example.com/test", nil)
-	response, err := service.processRecord(*req, 0)
+	response, err := service.processRecord(context.Background(), *req, 0)
+
+	if err != nil {
+		t.Fatalf("Transport errors should not abort the batch, got: %v", err)
+	}
 
-	if err == nil {
-		t.Fatal("Expected error but got none")
+	if !strings.Contains(response.Message, "error making request") {
+		t.Errorf("Message should contain 'error making request', got: %v", response.Message)
 	}
 
-	if !strings.Contains(err.Error(), "error making request") {
-		t.Errorf("Error message should contain 'error making request', got: %v", err)
+	if response.Message != "0-0 [attempts=1] - error making request: network error" {
+		t.Errorf("Unexpected message: %q", response.Message)
 	}
 
 	if response.Type != model.ERROR {
@@ -180,6 +217,285 @@ func TestProcessService_processRecord_Error(t *testing.T) {
 	}
 }
 
+func TestProcessService_processRecord_Retry(t *testing.T) {
+	tests := []struct {
+		name             string
+		retry            model.RetryPolicy
+		responses        []int
+		errs             []error
+		expectedType     model.ResponseType
+		expectedAttempts int
+		expectedPrefix   string
+	}{
+		{
+			name:             "Retries 503 until success",
+			retry:            model.RetryPolicy{MaxAttempts: 3, BaseBackoffMillis: 1},
+			responses:        []int{503, 503, 200},
+			expectedType:     model.SUCCESS,
+			expectedAttempts: 3,
+			expectedPrefix:   "0-200 [attempts=3] - ",
+		},
+		{
+			name:             "Gives up after max attempts",
+			retry:            model.RetryPolicy{MaxAttempts: 2, BaseBackoffMillis: 1},
+			responses:        []int{502, 502, 200},
+			expectedType:     model.ERROR,
+			expectedAttempts: 2,
+			expectedPrefix:   "0-502 [attempts=2] - ",
+		},
+		{
+			name:             "Non retryable status is not retried",
+			retry:            model.RetryPolicy{MaxAttempts: 3, BaseBackoffMillis: 1},
+			responses:        []int{400, 200},
+			expectedType:     model.ERROR,
+			expectedAttempts: 1,
+			expectedPrefix:   "0-400 [attempts=1] - ",
+		},
+		{
+			name:             "Custom retryable statuses",
+			retry:            model.RetryPolicy{MaxAttempts: 3, BaseBackoffMillis: 1, RetryOnStatus: []int{409}},
+			responses:        []int{409, 201},
+			expectedType:     model.SUCCESS,
+			expectedAttempts: 2,
+			expectedPrefix:   "0-201 [attempts=2] - ",
+		},
+		{
+			name:             "Transport error is retried",
+			retry:            model.RetryPolicy{MaxAttempts: 3, BaseBackoffMillis: 1},
+			responses:        []int{0, 200},
+			errs:             []error{errors.New("connection reset"), nil},
+			expectedType:     model.SUCCESS,
+			expectedAttempts: 2,
+			expectedPrefix:   "0-200 [attempts=2] - ",
+		},
+		{
+			name:             "Error kind not listed is not retried",
+			retry:            model.RetryPolicy{MaxAttempts: 3, BaseBackoffMillis: 1, RetryOnErrors: []string{"timeout"}},
+			responses:        []int{0, 200},
+			errs:             []error{errors.New("boom"), nil},
+			expectedType:     model.ERROR,
+			expectedAttempts: 1,
+			expectedPrefix:   "0-0 [attempts=1] - error making request: boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies []string
+			callCount := 0
+			mockService := &MockHttpService{
+				callFunc: func(record http.Request) ([]byte, int, error) {
+					body, _ := io.ReadAll(record.Body)
+					bodies = append(bodies, string(body))
+
+					status := tt.responses[callCount]
+					var err error
+					if tt.errs != nil {
+						err = tt.errs[callCount]
+					}
+					callCount++
+					if err != nil {
+						return nil, 0, err
+					}
+					return []byte("body"), status, nil
+				},
+			}
+
+			service := &ProcessService{
+				config:      model.Config{Retry: tt.retry},
+				httpService: mockService,
+			}
+
+			req, _ := http.NewRequest("POST", "https://api.example.com/test", strings.NewReader("payload"))
+			response, err := service.processRecord(context.Background(), *req, 0)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if response.Type != tt.expectedType {
+				t.Errorf("Expected type %v, got %v", tt.expectedType, response.Type)
+			}
+			if response.Attempts != tt.expectedAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.expectedAttempts, response.Attempts)
+			}
+			if !strings.HasPrefix(response.Message, tt.expectedPrefix) {
+				t.Errorf("Expected message starting with %q, got %q", tt.expectedPrefix, response.Message)
+			}
+
+			// Every attempt must send the full body again
+			for i, body := range bodies {
+				if body != "payload" {
+					t.Errorf("Attempt %d sent body %q, expected %q", i+1, body, "payload")
+				}
+			}
+		})
+	}
+}
+
+func TestProcessService_processRecord_RetryAfter(t *testing.T) {
+	tests := []struct {
+		name             string
+		config           model.Config
+		statuses         []int
+		expectedType     model.ResponseType
+		expectedAttempts int
+	}{
+		{
+			name:             "429 is re-sent without a retry policy",
+			statuses:         []int{429, 429, 200},
+			expectedType:     model.SUCCESS,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "Global scope pauses and re-sends",
+			config:           model.Config{RetryAfter: model.RetryAfter{Scope: "global"}},
+			statuses:         []int{503, 201},
+			expectedType:     model.SUCCESS,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "Stops after max attempts",
+			config:           model.Config{RetryAfter: model.RetryAfter{MaxAttempts: 1}},
+			statuses:         []int{429, 429, 200},
+			expectedType:     model.ERROR,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "Disabled leaves the status as an error",
+			config:           model.Config{RetryAfter: model.RetryAfter{Disabled: true}},
+			statuses:         []int{429, 200},
+			expectedType:     model.ERROR,
+			expectedAttempts: 1,
+		},
+		{
+			name: "Re-sends do not consume retry policy attempts",
+			config: model.Config{
+				Retry: model.RetryPolicy{MaxAttempts: 2, BaseBackoffMillis: 1, RetryOnStatus: []int{500}},
+			},
+			statuses:         []int{429, 500, 429, 200},
+			expectedType:     model.SUCCESS,
+			expectedAttempts: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callCount := 0
+			mockService := &MockHttpService{
+				callFunc: func(record http.Request) ([]byte, int, error) {
+					status := tt.statuses[callCount]
+					callCount++
+					return []byte("body"), status, nil
+				},
+				headerFunc: func(record http.Request) http.Header {
+					return http.Header{"Retry-After": []string{"0"}}
+				},
+			}
+
+			service := &ProcessService{
+				config:      tt.config,
+				httpService: mockService,
+			}
+
+			req, _ := http.NewRequest("GET", "https://api.example.com/test", nil)
+			response, err := service.processRecord(context.Background(), *req, 0)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if response.Type != tt.expectedType {
+				t.Errorf("Expected type %v, got %v", tt.expectedType, response.Type)
+			}
+			if response.Attempts != tt.expectedAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.expectedAttempts, response.Attempts)
+			}
+		})
+	}
+}
+
+func TestProcessService_ProcessAll_ResponseMetadata(t *testing.T) {
+	mockService := &MockHttpService{
+		callFunc: func(record http.Request) ([]byte, int, error) {
+			if record.URL.Path == "/down" {
+				return nil, 0, errors.New("connection refused")
+			}
+			return []byte(`{"id":"1"}`), 201, nil
+		},
+		headerFunc: func(record http.Request) http.Header {
+			return http.Header{"Location": {"/items/1"}}
+		},
+	}
+	service := &ProcessService{config: model.Config{}, httpService: mockService}
+
+	ok, _ := http.NewRequest("POST", "https://api.example.com/items", strings.NewReader("{}"))
+	ok.Header.Set("Authorization", "Bearer token")
+	down, _ := http.NewRequest("GET", "https://api.example.com/down", nil)
+	records := func(yield func(model.Record, error) bool) {
+		if !yield(model.Record{Index: 0, Request: ok, Row: []string{"a", "{}"}}, nil) {
+			return
+		}
+		yield(model.Record{Index: 1, Request: down, Row: []string{"b"}}, nil)
+	}
+
+	writer := &memoryWriter{}
+	if err := service.ProcessAll(context.Background(), records, writer); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	first := writer.responses[0]
+	if first.Method != "POST" || first.URL != "https://api.example.com/items" {
+		t.Errorf("Unexpected request metadata %s %s", first.Method, first.URL)
+	}
+	if first.RequestHeaders.Get("Authorization") != "Bearer token" {
+		t.Error("Expected the request headers")
+	}
+	if first.Headers.Get("Location") != "/items/1" || string(first.Body) != `{"id":"1"}` {
+		t.Errorf("Unexpected response metadata %v %q", first.Headers, first.Body)
+	}
+	if len(first.Row) != 2 || first.Row[0] != "a" {
+		t.Errorf("Expected the original row, got %v", first.Row)
+	}
+	if first.Error != "" || first.Attempts != 1 {
+		t.Errorf("Unexpected error %q or attempts %d", first.Error, first.Attempts)
+	}
+
+	second := writer.responses[1]
+	if second.Type != model.ERROR || !strings.Contains(second.Error, "connection refused") {
+		t.Errorf("Expected the transport error text, got %q", second.Error)
+	}
+	if second.Row[0] != "b" || second.Method != "GET" {
+		t.Errorf("Unexpected metadata for the failed row: %+v", second)
+	}
+}
+
+func TestProcessService_processRecord_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	callCount := 0
+	mockService := &MockHttpService{
+		callFunc: func(record http.Request) ([]byte, int, error) {
+			callCount++
+			cancel()
+			return nil, 0, context.Canceled
+		},
+	}
+
+	service := &ProcessService{
+		config:      model.Config{Retry: model.RetryPolicy{MaxAttempts: 5, BaseBackoffMillis: 1}},
+		httpService: mockService,
+	}
+
+	req, _ := http.NewRequest("GET", "https://api.example.com/test", nil)
+	_, err := service.processRecord(ctx, *req, 0)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+	if callCount != 1 {
+		t.Errorf("Expected no retry once the context is done, got %d calls", callCount)
+	}
+}
+
 func TestProcessService_ProcessAll(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -266,8 +582,8 @@ func TestProcessService_ProcessAll(t *testing.T) {
 				{nil, 0, errors.New("network timeout")},
 			},
 			expectedRespCount: 0,
-			expectedErrCount:  0,
-			expectError:       true,
+			expectedErrCount:  1,
+			expectError:       false,
 		},
 		{
 			name:    "Empty records list",
@@ -304,7 +620,9 @@ func TestProcessService_ProcessAll(t *testing.T) {
 				httpService: mockService,
 			}
 
-			respList, errList, err := service.ProcessAll(tt.records)
+			writer := &memoryWriter{}
+			err := service.ProcessAll(context.Background(), recordsOf(tt.records), writer)
+			respList, errList := writer.respList, writer.errList
 
 			if tt.expectError {
 				if err == nil {
@@ -341,7 +659,277 @@ func TestProcessService_ProcessAll(t *testing.T) {
 	}
 }
 
-func TestCreateResponseFromStatus(t *testing.T) {
+func TestProcessService_ProcessAll_ConcurrentKeepsOrder(t *testing.T) {
+	var inFlight, maxInFlight int32
+	mockService := &MockHttpService{
+		callFunc: func(record http.Request) ([]byte, int, error) {
+			current := atomic.AddInt32(&inFlight, 1)
+			for {
+				seen := atomic.LoadInt32(&maxInFlight)
+				if current <= seen || atomic.CompareAndSwapInt32(&maxInFlight, seen, current) {
+					break
+				}
+			}
+			defer atomic.AddInt32(&inFlight, -1)
+
+			// Earlier records take longer so they complete out of order
+			id, _ := strconv.Atoi(strings.TrimPrefix(record.URL.Path, "/"))
+			time.Sleep(time.Duration(20-id) * time.Millisecond)
+			if id%2 == 0 {
+				return []byte(record.URL.Path), 200, nil
+			}
+			return []byte(record.URL.Path), 500, nil
+		},
+	}
+
+	var records []http.Request
+	for i := 0; i < 20; i++ {
+		records = append(records, *createTestRequest(fmt.Sprintf("https://api.example.com/%d", i)))
+	}
+
+	service := &ProcessService{
+		config:      model.Config{Concurrency: 2},
+		args:        model.CommandLineArgs{Concurrency: 5},
+		httpService: mockService,
+	}
+
+	writer := &memoryWriter{}
+	err := service.ProcessAll(context.Background(), recordsOf(records), writer)
+	respList, errList := writer.respList, writer.errList
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(respList) != 10 || len(errList) != 10 {
+		t.Fatalf("Expected 10 successes and 10 errors, got %d and %d", len(respList), len(errList))
+	}
+	for i, resp := range respList {
+		expected := fmt.Sprintf("%d-200 [attempts=1] - /%d", i*2, i*2)
+		if resp != expected {
+			t.Errorf("Expected %q at position %d, got %q", expected, i, resp)
+		}
+	}
+	for i, errResp := range errList {
+		expected := fmt.Sprintf("%d-500 [attempts=1] - /%d", i*2+1, i*2+1)
+		if errResp != expected {
+			t.Errorf("Expected %q at position %d, got %q", expected, i, errResp)
+		}
+	}
+
+	if atomic.LoadInt32(&maxInFlight) > 5 {
+		t.Errorf("Expected at most 5 requests in flight, got %d", maxInFlight)
+	}
+	if atomic.LoadInt32(&maxInFlight) < 2 {
+		t.Errorf("Expected requests to run in parallel, max in flight was %d", maxInFlight)
+	}
+}
+
+func TestProcessService_ProcessAll_SkipsCheckpointedRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.tsv"+CheckpointSuffix)
+	if err := os.WriteFile(path, []byte("0\t200\tSUCCESS\n2\t400\tERROR\n"), 0644); err != nil {
+		t.Fatalf("Failed to write checkpoint: %v", err)
+	}
+	checkpoint, err := OpenCheckpoint(path, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer checkpoint.Close()
+
+	var called []string
+	mockService := &MockHttpService{
+		callFunc: func(record http.Request) ([]byte, int, error) {
+			called = append(called, record.URL.Path)
+			return []byte("ok"), 200, nil
+		},
+	}
+
+	service := &ProcessService{httpService: mockService, checkpoint: checkpoint}
+
+	records := []http.Request{
+		*createTestRequest("https://api.example.com/0"),
+		*createTestRequest("https://api.example.com/1"),
+		*createTestRequest("https://api.example.com/2"),
+		*createTestRequest("https://api.example.com/3"),
+	}
+
+	writer := &memoryWriter{}
+	if err := service.ProcessAll(context.Background(), recordsOf(records), writer); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Join(called, ",") != "/1,/3" {
+		t.Errorf("Expected only rows 1 and 3 to be sent, got %v", called)
+	}
+	if len(writer.respList) != 2 || len(writer.errList) != 0 {
+		t.Fatalf("Expected 2 successes and no errors, got %v and %v", writer.respList, writer.errList)
+	}
+	if fmt.Sprint(writer.indexes) != "[1 3]" {
+		t.Errorf("Responses should keep their original row index, got %v", writer.indexes)
+	}
+}
+
+func TestNewProcessService_WithCheckpoint(t *testing.T) {
+	checkpoint := &Checkpoint{}
+	service := NewProcessService(model.Config{}, model.CommandLineArgs{DryRun: true}, WithCheckpoint(checkpoint))
+
+	if service.checkpoint != checkpoint {
+		t.Error("Expected the checkpoint option to be applied")
+	}
+}
+
+// failingWriter rejects every response
+type failingWriter struct{}
+
+func (failingWriter) Write(int, model.Response) error {
+	return errors.New("disk full")
+}
+
+func TestProcessService_ProcessAll_WriterError(t *testing.T) {
+	service := &ProcessService{httpService: &MockHttpService{}}
+
+	records := []http.Request{
+		*createTestRequest("https://api.example.com/1"),
+		*createTestRequest("https://api.example.com/2"),
+	}
+
+	err := service.ProcessAll(context.Background(), recordsOf(records), failingWriter{})
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Expected the writer error to stop processing, got %v", err)
+	}
+}
+
+func TestProcessService_ProcessAll_ReadError(t *testing.T) {
+	records := func(yield func(model.Record, error) bool) {
+		if !yield(model.Record{Index: 0, Request: createTestRequest("https://api.example.com/0")}, nil) {
+			return
+		}
+		yield(model.Record{}, errors.New("malformed row"))
+	}
+
+	service := &ProcessService{httpService: &MockHttpService{}}
+	writer := &memoryWriter{}
+
+	err := service.ProcessAll(context.Background(), records, writer)
+	if err == nil || !strings.Contains(err.Error(), "malformed row") {
+		t.Fatalf("Expected the read error to be returned, got %v", err)
+	}
+
+	// Records read before the error are still processed and written
+	if len(writer.respList) != 1 {
+		t.Errorf("Expected 1 response written before the error, got %d", len(writer.respList))
+	}
+}
+
+func TestProcessService_ProcessAll_Interrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int32
+	mockService := &MockHttpService{
+		callFunc: func(record http.Request) ([]byte, int, error) {
+			if atomic.AddInt32(&calls, 1) == 2 {
+				cancel()
+			}
+			time.Sleep(10 * time.Millisecond)
+			return []byte("ok"), 200, nil
+		},
+	}
+
+	var records []http.Request
+	for i := 0; i < 20; i++ {
+		records = append(records, *createTestRequest(fmt.Sprintf("https://api.example.com/%d", i)))
+	}
+
+	service := &ProcessService{httpService: mockService}
+	writer := &memoryWriter{}
+
+	err := service.ProcessAll(ctx, recordsOf(records), writer)
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("Expected ErrInterrupted, got %v", err)
+	}
+
+	// The in-flight request completes and is written, nothing new is dispatched
+	sent := int(atomic.LoadInt32(&calls))
+	if sent >= len(records) {
+		t.Errorf("Expected dispatching to stop after the interrupt, %d requests sent", sent)
+	}
+	if len(writer.respList) != sent {
+		t.Errorf("Expected every completed request to be written, sent %d, written %d", sent, len(writer.respList))
+	}
+}
+
+func TestProcessService_ProcessAll_ShutdownTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+	rowOneSent := make(chan struct{})
+
+	mockService := &MockHttpService{
+		callFunc: func(record http.Request) ([]byte, int, error) {
+			if record.URL.Path == "/0" {
+				// Row 0 hangs, row 1 completes but waits behind it
+				<-rowOneSent
+				cancel()
+				<-release
+			} else {
+				close(rowOneSent)
+			}
+			return []byte("ok"), 200, nil
+		},
+	}
+
+	records := []http.Request{
+		*createTestRequest("https://api.example.com/0"),
+		*createTestRequest("https://api.example.com/1"),
+	}
+
+	service := &ProcessService{
+		config:      model.Config{Concurrency: 2, ShutdownTimeoutMillis: 50},
+		httpService: mockService,
+	}
+	writer := &memoryWriter{}
+
+	start := time.Now()
+	err := service.ProcessAll(ctx, recordsOf(records), writer)
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("Expected ErrInterrupted, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to give up after the shutdown timeout, took %v", elapsed)
+	}
+
+	// Row 1 is written even though row 0 never completed
+	if fmt.Sprint(writer.indexes) != "[1]" {
+		t.Errorf("Expected only row 1 to be written, got %v", writer.indexes)
+	}
+}
+
+func TestProcessService_workerCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   model.Config
+		args     model.CommandLineArgs
+		expected int
+	}{
+		{"Defaults to one worker", model.Config{}, model.CommandLineArgs{}, 1},
+		{"Config value", model.Config{Concurrency: 4}, model.CommandLineArgs{}, 4},
+		{"Flag overrides config", model.Config{Concurrency: 4}, model.CommandLineArgs{Concurrency: 8}, 8},
+		{"Negative values fall back to one", model.Config{Concurrency: -3}, model.CommandLineArgs{}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &ProcessService{config: tt.config, args: tt.args}
+			if got := service.workerCount(); got != tt.expected {
+				t.Errorf("Expected %d workers, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResponseClassifier_DefaultRules(t *testing.T) {
 	tests := []struct {
 		name         string
 		status       int
@@ -398,16 +986,17 @@ func TestCreateResponseFromStatus(t *testing.T) {
 		},
 	}
 
+	classifier, err := newResponseClassifier(model.Config{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			response := createResponseFromStatus(tt.status, tt.message)
-
-			if response.Type != tt.expectedType {
-				t.Errorf("Expected type %v, got %v", tt.expectedType, response.Type)
-			}
+			responseType := classifier.classify(tt.status, []byte(tt.message))
 
-			if response.Message != tt.message {
-				t.Errorf("Expected message %q, got %q", tt.message, response.Message)
+			if responseType != tt.expectedType {
+				t.Errorf("Expected type %v, got %v", tt.expectedType, responseType)
 			}
 		})
 	}
@@ -418,6 +1007,7 @@ func TestFormatResponse(t *testing.T) {
 		name           string
 		index          int
 		status         int
+		attempts       int
 		response       []byte
 		expectedFormat string
 	}{
@@ -425,42 +1015,55 @@ func TestFormatResponse(t *testing.T) {
 			name:           "Simple response",
 			index:          0,
 			status:         200,
+			attempts:       1,
 			response:       []byte("OK"),
-			expectedFormat: "0-200 - OK",
+			expectedFormat: "0-200 [attempts=1] - OK",
 		},
 		{
 			name:           "JSON response",
 			index:          5,
 			status:         201,
+			attempts:       1,
 			response:       []byte(`{"id":"123","status":"created"}`),
-			expectedFormat: `5-201 - {"id":"123","status":"created"}`,
+			expectedFormat: `5-201 [attempts=1] - {"id":"123","status":"created"}`,
 		},
 		{
 			name:           "Error response",
 			index:          10,
 			status:         404,
+			attempts:       1,
 			response:       []byte("Resource not found"),
-			expectedFormat: "10-404 - Resource not found",
+			expectedFormat: "10-404 [attempts=1] - Resource not found",
 		},
 		{
 			name:           "Empty response",
 			index:          1,
 			status:         204,
+			attempts:       1,
 			response:       []byte(""),
-			expectedFormat: "1-204 - ",
+			expectedFormat: "1-204 [attempts=1] - ",
 		},
 		{
 			name:           "Large index",
 			index:          9999,
 			status:         200,
+			attempts:       1,
+			response:       []byte("test"),
+			expectedFormat: "9999-200 [attempts=1] - test",
+		},
+		{
+			name:           "Retried response",
+			index:          3,
+			status:         200,
+			attempts:       4,
 			response:       []byte("test"),
-			expectedFormat: "9999-200 - test",
+			expectedFormat: "3-200 [attempts=4] - test",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := formatResponse(tt.index, tt.status, tt.response)
+			result := formatResponse(tt.index, tt.status, tt.attempts, tt.response)
 
 			if result != tt.expectedFormat {
 				t.Errorf("Expected format %q, got %q", tt.expectedFormat, result)
@@ -469,7 +1072,7 @@ func TestFormatResponse(t *testing.T) {
 			// Verify format structure
 			parts := strings.Split(result, " - ")
 			if len(parts) != 2 {
-				t.Errorf("Expected format 'index-status - body', got %q", result)
+				t.Errorf("Expected format 'index-status [attempts=n] - body', got %q", result)
 			}
 
 			indexStatus := strings.Split(parts[0], "-")
@@ -480,11 +1083,14 @@ func TestFormatResponse(t *testing.T) {
 	}
 }
 
-func TestLoadClient(t *testing.T) {
-	client := loadClient()
+func TestNewHttpClient(t *testing.T) {
+	client, err := NewHttpClient(model.Config{})
+	if err != nil {
+		t.Fatalf("NewHttpClient failed: %v", err)
+	}
 
 	if client == nil {
-		t.Fatal("loadClient returned nil")
+		t.Fatal("NewHttpClient returned nil")
 	}
 
 	if client.Transport == nil {
@@ -501,8 +1107,8 @@ func TestLoadClient(t *testing.T) {
 		t.Fatal("TLS config should not be nil")
 	}
 
-	if !transport.TLSClientConfig.InsecureSkipVerify {
-		t.Error("Expected InsecureSkipVerify to be true")
+	if transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("Expected InsecureSkipVerify to be false by default")
 	}
 }
 
@@ -549,6 +1155,17 @@ func TestCreateHttpServiceInner(t *testing.T) {
 	}
 }
 
+// recordsOf streams the requests as records indexed by position
+func recordsOf(requests []http.Request) iter.Seq2[model.Record, error] {
+	return func(yield func(model.Record, error) bool) {
+		for i := range requests {
+			if !yield(model.Record{Index: i, Request: &requests[i]}, nil) {
+				return
+			}
+		}
+	}
+}
+
 // Helper function to create test HTTP requests
 func createTestRequest(url string) *http.Request {
 	req, _ := http.NewRequest("GET", url, bytes.NewBuffer([]byte("")))
@@ -605,7 +1222,9 @@ func TestProcessService_Integration(t *testing.T) {
 		*createTestRequest("https://api.example.com/test3"),
 	}
 
-	respList, errList, err := service.ProcessAll(requests)
+	writer := &memoryWriter{}
+	err := service.ProcessAll(context.Background(), recordsOf(requests), writer)
+	respList, errList := writer.respList, writer.errList
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
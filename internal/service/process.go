@@ -3,73 +3,466 @@ package service
 import (
 	"batchRequestsRecover/internal/model"
 	"batchRequestsRecover/internal/util"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"iter"
+	"maps"
+	"net"
 	"net/http"
+	"slices"
+	"sync"
+	"time"
 )
 
 const (
-	httpSuccessMin = 200
-	httpSuccessMax = 300
+	defaultMaxIdleConnsPerHost = 100
+	defaultIdleConnTimeout     = 90 * time.Second
 )
 
 type ProcessService struct {
 	config      model.Config
 	args        model.CommandLineArgs
 	httpService HttpService
+	limiter     *RateLimiter
+	pause       pauseGate
+	checkpoint  *Checkpoint
+
+	// the response handling config is compiled once, on first use
+	responseOnce sync.Once
+	classifier   *responseClassifier
+	extractor    *responseExtractor
+	// steps holds the templates and the extract columns of each step
+	steps       []*compiledStep
+	responseErr error
+}
+
+type ProcessServiceOption func(*ProcessService)
+
+func NewProcessService(config model.Config, args model.CommandLineArgs, opts ...ProcessServiceOption) *ProcessService {
+	service := &ProcessService{
+		config:      config,
+		args:        args,
+		httpService: createHttpService(config, args),
+		limiter:     NewRateLimiter(config.RateLimit, args.SleepMillis),
+	}
+
+	for _, opt := range opts {
+		opt(service)
+	}
+
+	return service
+}
+
+// WithHttpClient makes the real HTTP service send the requests through
+// client instead of building its own. It has no effect in dry-run mode.
+func WithHttpClient(client *http.Client) ProcessServiceOption {
+	return func(s *ProcessService) {
+		if real, ok := s.httpService.(*HttpServiceReal); ok {
+			real.client = client
+		}
+	}
+}
+
+// WithCheckpoint records every completed row in the checkpoint and skips
+// the rows it already lists.
+func WithCheckpoint(checkpoint *Checkpoint) ProcessServiceOption {
+	return func(s *ProcessService) {
+		s.checkpoint = checkpoint
+	}
+}
+
+// job is a single record handed to a worker, tagged with its row index.
+type job struct {
+	index  int
+	record *http.Request
+	row    []string
+	raw    []byte
+	values map[string]string
 }
 
-func NewProcessService(config model.Config, args model.CommandLineArgs) *ProcessService {
-	return &ProcessService{config: config, args: args, httpService: createHttpService(config, args)}
+// result is the outcome of a job, collected back in row-index order.
+type result struct {
+	index    int
+	response model.Response
+	err      error
 }
 
-func (s *ProcessService) ProcessAll(records []http.Request) ([]string, []string, error) {
-	respList := make([]string, 0, len(records))
-	errList := make([]string, 0)
-	for i, record := range records {
+// ErrInterrupted is returned by ProcessAll when the context is cancelled
+// before every record has been processed.
+var ErrInterrupted = errors.New("processing interrupted")
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// ProcessAll sends the records using a pool of workers and hands every
+// response to the writer as soon as it is available, in row-index order.
+// Records are pulled from the iterator only as workers become available.
+//
+// When ctx is cancelled no new record is dispatched; the requests already
+// in flight get up to the shutdown timeout to complete, every collected
+// response is written and ErrInterrupted is returned.
+func (s *ProcessService) ProcessAll(ctx context.Context, records iter.Seq2[model.Record, error], writer ResponseWriter) error {
+	// In-flight requests must survive the interrupt to be able to complete,
+	// so they run on a context that is only cancelled when ProcessAll returns
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+
+	// Invalid rules must stop the run before any request is sent
+	if err := s.compileResponseHandling(); err != nil {
+		return err
+	}
+
+	workers := s.workerCount()
+	jobs := make(chan job)
+	results := make(chan result)
+	done := make(chan struct{})
+	// window bounds how many records can be in flight or waiting to be
+	// emitted, so a slow row never lets the reorder buffer grow unbounded.
+	window := make(chan struct{}, workers*4)
 
-		responseMsg, err := s.processRecord(record, i)
-		if err != nil {
-			return respList, errList, fmt.Errorf("error processing record: %w", err)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.worker(workCtx, jobs, results, done)
+		}()
+	}
+
+	// readErr is only read after results is closed, which happens after
+	// the dispatcher has returned and closed jobs.
+	var readErr error
+	go func() {
+		defer close(jobs)
+		for record, err := range records {
+			if err != nil {
+				readErr = err
+				return
+			}
+			if s.checkpoint.IsDone(record.Index) {
+				continue
+			}
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job{index: record.Index, record: record.Request, row: record.Row, raw: record.Raw, values: record.Values}:
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
 		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]model.Response)
+	next := 0
+	interrupted := ctx.Done()
+	var shutdown <-chan time.Time
+
+collect:
+	for {
+		select {
+		case res, ok := <-results:
+			if !ok {
+				break collect
+			}
+			if res.err != nil {
+				close(done)
+				return fmt.Errorf("error processing record: %w", res.err)
+			}
+			pending[res.index] = res.response
+
+			for {
+				for s.checkpoint.IsDone(next) {
+					next++
+				}
+				responseMsg, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				<-window
+
+				if err := writer.Write(next, responseMsg); err != nil {
+					close(done)
+					return fmt.Errorf("error writing response: %w", err)
+				}
+				next++
+			}
+
+		case <-interrupted:
+			timeout := s.shutdownTimeout()
+			fmt.Printf("Interrupted, waiting up to %s for in-flight requests\n", timeout)
+			interrupted = nil
+			shutdown = time.After(timeout)
+
+		case <-shutdown:
+			fmt.Println("Shutdown timeout reached, cancelling in-flight requests")
+			close(done)
+			break collect
+		}
+	}
 
-		if responseMsg.Type == model.SUCCESS {
-			respList = append(respList, responseMsg.Message)
+	if ctx.Err() != nil {
+		// Rows after a gap are written too: the checkpoint tracks each index,
+		// so a resumed run only re-sends the rows that are really missing
+		if err := s.writePending(pending, writer); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrInterrupted, ctx.Err())
+	}
+	if readErr != nil {
+		return fmt.Errorf("error reading records: %w", readErr)
+	}
+	return nil
+}
+
+// writePending writes the responses still waiting for an earlier row, in index order.
+func (s *ProcessService) writePending(pending map[int]model.Response, writer ResponseWriter) error {
+	for _, index := range slices.Sorted(maps.Keys(pending)) {
+		if err := writer.Write(index, pending[index]); err != nil {
+			return fmt.Errorf("error writing response: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *ProcessService) worker(ctx context.Context, jobs <-chan job, results chan<- result, done <-chan struct{}) {
+	for j := range jobs {
+		var responseMsg model.Response
+		var err error
+		if len(s.config.Steps) > 0 {
+			responseMsg, err = s.processSteps(ctx, j.values, j.index)
 		} else {
-			errList = append(errList, responseMsg.Message)
+			responseMsg, err = s.processRecord(ctx, *j.record, j.index)
+		}
+		responseMsg.Row = j.row
+		responseMsg.Raw = j.raw
+		select {
+		case results <- result{index: j.index, response: responseMsg, err: err}:
+		case <-done:
+			return
 		}
+	}
+}
 
-		util.DelayFor(s.args.SleepMillis)
+func (s *ProcessService) shutdownTimeout() time.Duration {
+	if s.config.ShutdownTimeoutMillis > 0 {
+		return time.Duration(s.config.ShutdownTimeoutMillis) * time.Millisecond
 	}
-	return respList, errList, nil
+	return defaultShutdownTimeout
 }
 
-func (s *ProcessService) processRecord(record http.Request, index int) (res model.Response, err error) {
+// workerCount resolves the pool size: the command line wins over the config,
+// and anything below one means sequential processing.
+func (s *ProcessService) workerCount() int {
+	if s.args.Concurrency > 0 {
+		return s.args.Concurrency
+	}
+	if s.config.Concurrency > 0 {
+		return s.config.Concurrency
+	}
+	return 1
+}
 
-	response, status, err := s.httpService.call(record)
+// processRecord sends a record, retrying it according to the retry policy,
+// then checks the assertions of a successful response and extracts its values.
+// Transport errors that survive every attempt are reported as an error
+// response with status 0, so a single record never stops the batch.
+// An error is only returned when ctx is done before the record completes.
+func (s *ProcessService) processRecord(ctx context.Context, record http.Request, index int) (model.Response, error) {
+	res, err := s.sendRecord(ctx, record, index)
 	if err != nil {
-		return model.Response{Type: model.ERROR}, fmt.Errorf("error making request: %w", err)
+		return res, err
 	}
+	s.checkResponse(&res, index)
+	return res, nil
+}
+
+// sendRecord is processRecord without the assertions and the extract: the
+// response is only classified by the success and skip rules.
+func (s *ProcessService) sendRecord(ctx context.Context, record http.Request, index int) (res model.Response, err error) {
+	policy := newRetryPolicy(s.config.Retry)
+
+	var response []byte
+	var status int
+	var header http.Header
+	var latency time.Duration
+	attempt, throttled := 0, 0
+	for {
+		attempt++
+		if err := s.pause.wait(ctx); err != nil {
+			return model.Response{Type: model.ERROR, Attempts: attempt}, err
+		}
+		if err := s.limiter.Wait(ctx); err != nil {
+			return model.Response{Type: model.ERROR, Attempts: attempt}, err
+		}
+
+		start := time.Now()
+		response, status, header, err = s.httpService.call(ctx, record)
+		latency = time.Since(start)
+		if ctx.Err() != nil {
+			return model.Response{Type: model.ERROR, Attempts: attempt}, ctx.Err()
+		}
+
+		if wait, ok := retryAfterDelay(s.config.RetryAfter, status, header, throttled); ok {
+			throttled++
+			if err := s.waitRetryAfter(ctx, index, status, wait); err != nil {
+				return model.Response{Type: model.ERROR, Attempts: attempt}, err
+			}
+		} else {
+			// Re-sends requested by the server do not count against the retry policy
+			if !policy.shouldRetry(status, err, attempt-throttled) {
+				break
+			}
+			delay := policy.backoff(attempt - throttled)
+			fmt.Printf("Retrying record %d in %s (attempt %d/%d)\n", index, delay, attempt-throttled+1, policy.maxAttempts)
+			if err := util.SleepContext(ctx, delay); err != nil {
+				return model.Response{Type: model.ERROR, Attempts: attempt}, err
+			}
+		}
 
-	formattedResponse := formatResponse(index, status, response)
+		if err := rewindBody(&record); err != nil {
+			return model.Response{Type: model.ERROR, Attempts: attempt}, fmt.Errorf("error retrying request: %w", err)
+		}
+	}
 
-	return createResponseFromStatus(status, formattedResponse), nil
+	if err != nil {
+		message := formatResponse(index, 0, attempt, []byte(fmt.Sprintf("error making request: %v", err)))
+		res = model.Response{Type: model.ERROR, Message: message, Error: err.Error()}
+	} else {
+		if err := s.compileResponseHandling(); err != nil {
+			return model.Response{Type: model.ERROR, Attempts: attempt}, err
+		}
+		res = model.Response{
+			Type:    s.classifier.classify(status, response),
+			Message: formatResponse(index, status, attempt, response),
+			Status:  status,
+			Headers: header,
+			Body:    response,
+		}
+	}
+	res.Attempts = attempt
+	res.Method = record.Method
+	res.URL = record.URL.String()
+	res.RequestHeaders = record.Header
+	res.Latency = latency
+	return res, nil
 }
 
-func createResponseFromStatus(status int, message string) model.Response {
-	if status >= httpSuccessMin && status < httpSuccessMax {
-		return model.Response{Type: model.SUCCESS, Message: message}
+// checkResponse fails a successful response that does not pass the
+// assertions or the response schema, and extracts the values of the ones
+// that do.
+func (s *ProcessService) checkResponse(res *model.Response, index int) {
+	if res.Type != model.SUCCESS {
+		return
+	}
+	if failure := s.classifier.assertions.check(res.Body); failure != nil {
+		res.Type = model.ERROR
+		res.Error = failure.Error()
+		res.Message = formatResponse(index, res.Status, res.Attempts, []byte(res.Error+" - "+string(res.Body)))
+		return
 	}
-	return model.Response{Type: model.ERROR, Message: message}
+	res.Extracted = s.extractor.extract(res.Headers, res.Body)
 }
 
-func formatResponse(index, status int, response []byte) string {
-	return fmt.Sprintf("%d-%d - %s", index, status, string(response))
+// waitRetryAfter pauses the current worker, or the whole run when the
+// retry_after scope is global, for the wait requested by the server.
+func (s *ProcessService) waitRetryAfter(ctx context.Context, index, status int, wait time.Duration) error {
+	if s.config.RetryAfter.Scope == retryAfterScopeGlobal {
+		fmt.Printf("Record %d got %d, pausing all workers for %s as requested by Retry-After\n", index, status, wait)
+		s.pause.extend(wait)
+		return nil
+	}
+	fmt.Printf("Record %d got %d, waiting %s as requested by Retry-After\n", index, status, wait)
+	return util.SleepContext(ctx, wait)
+}
+
+// rewindBody restores the request body consumed by the previous attempt.
+func rewindBody(record *http.Request) error {
+	if record.GetBody == nil {
+		return nil
+	}
+	body, err := record.GetBody()
+	if err != nil {
+		return err
+	}
+	record.Body = body
+	return nil
 }
 
-func loadClient() *http.Client {
+// compileResponseHandling compiles the success and skip rules, the
+// assertions and the extract columns of the config and of its steps.
+func (s *ProcessService) compileResponseHandling() error {
+	s.responseOnce.Do(func() {
+		s.classifier, s.responseErr = newResponseClassifier(s.config)
+		if s.responseErr == nil {
+			s.extractor, s.responseErr = newResponseExtractor(s.config.Extract)
+		}
+		if s.responseErr == nil {
+			s.steps, s.responseErr = compileSteps(s.config.Steps, s.config.Headers)
+		}
+	})
+	return s.responseErr
+}
+
+func formatResponse(index, status, attempts int, response []byte) string {
+	return fmt.Sprintf("%d-%d [attempts=%d] - %s", index, status, attempts, string(response))
+}
+
+// NewHttpClient builds the HTTP client shared by the workers of a run,
+// applying the connection timeouts, the connection pool and the TLS settings
+// of the config. It fails when the TLS settings are invalid or their files
+// cannot be loaded.
+func NewHttpClient(config model.Config) (*http.Client, error) {
+	tlsConfig, err := loadTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	timeouts := config.Timeouts
+	pool := config.Transport
+	maxIdlePerHost := pool.MaxIdleConnsPerHost
+	if maxIdlePerHost <= 0 {
+		maxIdlePerHost = defaultMaxIdleConnsPerHost
+	}
+	idleTimeout := millis(pool.IdleTimeoutMillis)
+	if idleTimeout <= 0 {
+		idleTimeout = defaultIdleConnTimeout
+	}
+	dialer := &net.Dialer{
+		Timeout:   millis(timeouts.ConnectMillis),
+		KeepAlive: 30 * time.Second,
+	}
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   millis(timeouts.TLSHandshakeMillis),
+		ResponseHeaderTimeout: millis(timeouts.ResponseHeaderMillis),
+		MaxIdleConnsPerHost:   maxIdlePerHost,
+		IdleConnTimeout:       idleTimeout,
+		DisableKeepAlives:     pool.DisableKeepAlives,
+		ForceAttemptHTTP2:     !pool.DisableHTTP2,
+	}
+	if pool.DisableHTTP2 {
+		// a non-nil empty map keeps the transport from negotiating h2
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
 	}
-	return &http.Client{Transport: tr}
+	return &http.Client{Transport: tr}, nil
+}
+
+func millis(value int) time.Duration {
+	return time.Duration(value) * time.Millisecond
 }
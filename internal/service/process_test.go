@@ -316,7 +316,7 @@ func TestParserService_parse(t *testing.T) {
 			service := &ParserService{config: tt.config}
 			content := []byte(tt.csvContent)
 
-			requests, err := service.parse(content)
+			requests, err := collectRequests(service.records(bytes.NewReader(content)))
 
 			if tt.expectedErr {
 				if err == nil {
@@ -336,58 +336,334 @@ func TestParserService_parse(t *testing.T) {
 	}
 }
 
-func TestParserService_readFile(t *testing.T) {
+func TestParserService_Records(t *testing.T) {
 	// Create a temporary test file
 	tmpDir := t.TempDir()
 	testFile := filepath.Join(tmpDir, "test.csv")
 
-	testContent := "col1\tcol2\tcol3\nval1\tval2\tval3"
+	testContent := "val1\tval2\n\nval3\tval4"
 	err := os.WriteFile(testFile, []byte(testContent), 0644)
 	if err != nil {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
+	config := model.Config{
+		ApiEndpoint: "https://api.example.com/{a}",
+		Method:      "GET",
+		PathVars:    []string{"a"},
+		QueryVars:   []string{"b"},
+	}
+
 	tests := []struct {
-		name        string
-		filePath    string
-		expectError bool
+		name          string
+		filePath      string
+		expectedURLs  []string
+		expectedError bool
 	}{
 		{
-			name:        "Read existing file",
-			filePath:    testFile,
-			expectError: false,
+			name:         "Read existing file",
+			filePath:     testFile,
+			expectedURLs: []string{"https://api.example.com/val1?b=val2", "https://api.example.com/val3?b=val4"},
 		},
 		{
-			name:        "Read non-existent file",
-			filePath:    filepath.Join(tmpDir, "nonexistent.csv"),
-			expectError: true,
+			name:          "Read non-existent file",
+			filePath:      filepath.Join(tmpDir, "nonexistent.csv"),
+			expectedError: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := &ParserService{}
-			content, err := service.readFile(tt.filePath)
+			service := NewParserService(config)
 
-			if tt.expectError {
-				if err == nil {
+			var urls []string
+			var lastErr error
+			for record, err := range service.Records(tt.filePath) {
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				if record.Index != len(urls) {
+					t.Errorf("Expected index %d, got %d", len(urls), record.Index)
+				}
+				urls = append(urls, record.Request.URL.String())
+			}
+
+			if tt.expectedError {
+				if lastErr == nil {
 					t.Error("Expected error but got none")
 				}
 				return
 			}
 
-			if err != nil {
-				t.Fatalf("Unexpected error: %v", err)
+			if lastErr != nil {
+				t.Fatalf("Unexpected error: %v", lastErr)
+			}
+			if strings.Join(urls, ",") != strings.Join(tt.expectedURLs, ",") {
+				t.Errorf("Expected URLs %v, got %v", tt.expectedURLs, urls)
+			}
+		})
+	}
+}
+
+func TestParserService_Records_WithHeader(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedURLs  []string
+		expectedError string
+	}{
+		{
+			name:         "Columns mapped by name",
+			content:      "status,body,id\nactive,{},1\ninactive,{},2\n",
+			expectedURLs: []string{"https://api.example.com/1?status=active", "https://api.example.com/2?status=inactive"},
+		},
+		{
+			name:          "Missing columns fail before any request",
+			content:       "state,payload\nactive,{}\n",
+			expectedError: "missing columns in the header: id, status, body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), "test.csv")
+			if err := os.WriteFile(testFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			service := NewParserService(model.Config{
+				ApiEndpoint:  "https://api.example.com/{id}",
+				Method:       "POST",
+				PathVars:     []string{"id"},
+				QueryVars:    []string{"status"},
+				HasBody:      true,
+				BodyColumn:   "body",
+				HasHeader:    true,
+				CSVDelimiter: ",",
+			})
+
+			var urls []string
+			for record, err := range service.Records(testFile) {
+				if err != nil {
+					if tt.expectedError == "" || !strings.Contains(err.Error(), tt.expectedError) {
+						t.Fatalf("Expected error %q, got %v", tt.expectedError, err)
+					}
+					continue
+				}
+				urls = append(urls, record.Request.URL.String())
 			}
 
-			if len(content) == 0 {
-				t.Error("Expected content but got empty")
+			if strings.Join(urls, ",") != strings.Join(tt.expectedURLs, ",") {
+				t.Errorf("Expected URLs %v, got %v", tt.expectedURLs, urls)
 			}
 		})
 	}
 }
 
-func TestParserService_readFile_WithBOM(t *testing.T) {
+func TestParserService_Records_StopsEarly(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.csv")
+	if err := os.WriteFile(testFile, []byte("1\n2\n3\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	service := NewParserService(model.Config{ApiEndpoint: "https://api.example.com/{id}", Method: "GET", PathVars: []string{"id"}})
+
+	count := 0
+	for _, err := range service.Records(testFile) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		count++
+		if count == 2 {
+			break
+		}
+	}
+	if count != 2 {
+		t.Errorf("Expected to stop after 2 records, got %d", count)
+	}
+}
+
+func TestParserService_Records_Raw(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.csv")
+	content := "id,body\r\n1,\"{\"\"a\"\": 1}\"\r\n\r\n2,\"multi\nline\"\n  \n3,plain"
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	service := NewParserService(model.Config{
+		ApiEndpoint:  "https://api.example.com/{id}",
+		Method:       "POST",
+		PathVars:     []string{"id"},
+		HasBody:      true,
+		CSVDelimiter: ",",
+		HasHeader:    true,
+	})
+
+	var raws []string
+	for record, err := range service.Records(testFile) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		raws = append(raws, string(record.Raw))
+	}
+
+	expected := []string{"1,\"{\"\"a\"\": 1}\"\r\n", "2,\"multi\nline\"\n", "3,plain"}
+	if len(raws) != len(expected) {
+		t.Fatalf("Expected %d rows, got %d: %q", len(expected), len(raws), raws)
+	}
+	for i := range expected {
+		if raws[i] != expected[i] {
+			t.Errorf("Row %d: expected raw %q, got %q", i, expected[i], raws[i])
+		}
+	}
+
+	header, err := service.Header(testFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(header.Raw) != "id,body\r\n" {
+		t.Errorf("Expected the raw header, got %q", header.Raw)
+	}
+	if len(header.Row) != 2 || header.Row[0] != "id" || header.Row[1] != "body" {
+		t.Errorf("Expected the header fields, got %v", header.Row)
+	}
+
+	service = NewParserService(model.Config{})
+	if header, err := service.Header(testFile); err != nil || header.Raw != nil || header.Row != nil {
+		t.Errorf("Expected no header without has_header, got %+v, %v", header, err)
+	}
+}
+
+func TestParserService_Records_Steps(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.csv")
+	if err := os.WriteFile(testFile, []byte("id\tname\n1\tAda\n2\tAlan\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	service := NewParserService(model.Config{
+		Method:    "GET",
+		HasHeader: true,
+		Steps:     []model.Step{{Name: "get", ApiEndpoint: "https://api.example.com/users/{{.id}}"}},
+	})
+
+	var records []model.Record
+	for record, err := range service.Records(testFile) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	for _, record := range records {
+		if record.Request != nil {
+			t.Errorf("Record %d: the steps build their own requests", record.Index)
+		}
+	}
+	if records[1].Values["id"] != "2" || records[1].Values["name"] != "Alan" {
+		t.Errorf("Unexpected row values %v", records[1].Values)
+	}
+}
+
+func TestParserService_Records_Templates(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.csv")
+	content := "id\tname\ttenant\tnote\n7\tAda \"Countess\" Lovelace\tacme\t\n"
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	service := NewParserService(model.Config{
+		ApiEndpoint:  "https://{{.tenant}}.example.com/users/{id}",
+		Method:       "PUT",
+		Headers:      map[string]string{"X-Name": "{{upper .tenant}}", "Content-Type": "application/json"},
+		PathVars:     []string{"id"},
+		HasHeader:    true,
+		BodyTemplate: `{"name":{{json .name}},"note":{{.note | default "none" | json}}}`,
+	})
+
+	requests, err := service.ReadAndParse(testFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(requests))
+	}
+
+	request := requests[0]
+	if request.URL.String() != "https://acme.example.com/users/7" {
+		t.Errorf("Unexpected URL %s", request.URL)
+	}
+	if request.Header.Get("X-Name") != "ACME" || request.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers %v", request.Header)
+	}
+	body, _ := io.ReadAll(request.Body)
+	if string(body) != `{"name":"Ada \"Countess\" Lovelace","note":"none"}` {
+		t.Errorf("Unexpected body %s", body)
+	}
+}
+
+func TestParserService_Records_TemplateError(t *testing.T) {
+	service := NewParserService(model.Config{
+		ApiEndpoint:  "https://api.example.com",
+		BodyTemplate: `{"id":"{{.ids}}"}`,
+		HasHeader:    true,
+	})
+
+	_, err := collectRequests(service.records(strings.NewReader("id\n1\n")))
+	if err == nil || !strings.Contains(err.Error(), "body_template") {
+		t.Errorf("Expected an error naming the template, got %v", err)
+	}
+}
+
+func TestParserService_records_EncodesURL(t *testing.T) {
+	service := NewParserService(model.Config{
+		ApiEndpoint: "https://api.example.com/users/{name}?version=2",
+		Method:      "GET",
+		PathVars:    []string{"name"},
+		QueryVars:   []string{"filter"},
+	})
+
+	requests, err := collectRequests(service.records(strings.NewReader("Zoë Smith\ttype=admin&active\n")))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "https://api.example.com/users/Zo%C3%AB%20Smith?version=2&filter=type%3Dadmin%26active"
+	if len(requests) != 1 || requests[0].URL.String() != expected {
+		t.Fatalf("Expected %s, got %v", expected, requests)
+	}
+	if requests[0].URL.Query().Get("filter") != "type=admin&active" {
+		t.Errorf("Expected the query value to survive the round trip, got %q", requests[0].URL.Query().Get("filter"))
+	}
+}
+
+func TestParserService_records_EscapesTemplatedURL(t *testing.T) {
+	service := NewParserService(model.Config{
+		ApiEndpoint: "https://api.example.com/files/{{pathEscape .path}}?q={{queryEscape .q}}",
+		Method:      "GET",
+		HasHeader:   true,
+	})
+
+	requests, err := collectRequests(service.records(strings.NewReader("path\tq\nQ1 report/v2?#\ta+b c\n")))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "https://api.example.com/files/Q1%20report%2Fv2%3F%23?q=a%2Bb+c"
+	if len(requests) != 1 || requests[0].URL.String() != expected {
+		t.Fatalf("Expected %s, got %v", expected, requests)
+	}
+	if requests[0].URL.Query().Get("q") != "a+b c" {
+		t.Errorf("Expected the query value to survive the round trip, got %q", requests[0].URL.Query().Get("q"))
+	}
+}
+
+func TestParserService_Records_WithBOM(t *testing.T) {
 	tmpDir := t.TempDir()
 	testFile := filepath.Join(tmpDir, "test_bom.csv")
 
@@ -401,20 +677,60 @@ func TestParserService_readFile_WithBOM(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	service := &ParserService{}
-	content, err := service.readFile(testFile)
+	service := NewParserService(model.Config{ApiEndpoint: "https://api.example.com/{a}", PathVars: []string{"a", "b"}})
+	for record, err := range service.Records(testFile) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
 
-	if err != nil {
-		t.Fatalf("Unexpected error: %v", err)
+		// Verify BOM was removed
+		if bytes.HasPrefix([]byte(record.Row[0]), bom) {
+			t.Error("BOM was not removed from content")
+		}
+		if record.Row[0] != "col1" || record.Row[1] != "col2" {
+			t.Errorf("Expected row [col1 col2], got %v", record.Row)
+		}
 	}
+}
 
-	// Verify BOM was removed
-	if bytes.HasPrefix(content, bom) {
-		t.Error("BOM was not removed from content")
+func TestParserService_Records_InputEncoding(t *testing.T) {
+	tmpDir := t.TempDir()
+	tests := []struct {
+		name     string
+		encoding string
+		content  []byte
+	}{
+		{name: "UTF-16LE with BOM", content: []byte{0xFF, 0xFE, 'c', 0, 'a', 0, 'f', 0, 0xE9, 0, '\t', 0, 0x1C, 0x20, 'x', 0, 0x1D, 0x20, '\r', 0, '\n', 0}},
+		{name: "Windows-1252", encoding: "windows-1252", content: []byte("caf\xE9\t\x93x\x94\r\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testFile := filepath.Join(tmpDir, "input.tsv")
+			if err := os.WriteFile(testFile, tt.content, 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+			service := NewParserService(model.Config{
+				ApiEndpoint:   "https://api.example.com/{name}",
+				PathVars:      []string{"name"},
+				QueryVars:     []string{"q"},
+				InputEncoding: tt.encoding,
+			})
+
+			requests, err := service.ReadAndParse(testFile)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			expected := "https://api.example.com/caf%C3%A9?q=%E2%80%9Cx%E2%80%9D"
+			if len(requests) != 1 || requests[0].URL.String() != expected {
+				t.Errorf("Expected %s, got %v", expected, requests)
+			}
+		})
 	}
 
-	if !bytes.Equal(content, testContent) {
-		t.Errorf("Expected content %v, got %v", testContent, content)
+	service := NewParserService(model.Config{InputEncoding: "ebcdic"})
+	if _, err := service.ReadAndParse(filepath.Join(tmpDir, "input.tsv")); err == nil {
+		t.Error("Expected an error for an unknown encoding")
 	}
 }
 
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"batchRequestsRecover/internal/util"
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter throttles requests with one or more token buckets.
+// Every request takes a token from each bucket, and waits until all of them
+// can afford it, so the strictest limit always wins.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets []*tokenBucket
+}
+
+type tokenBucket struct {
+	rate     float64 // tokens added per second
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// NewRateLimiter builds a limiter from the config. When no rate limit is
+// configured it falls back to the legacy sleep interval, spacing requests
+// sleepMillis apart. It returns nil when there is nothing to limit.
+func NewRateLimiter(limit model.RateLimit, sleepMillis int) *RateLimiter {
+	now := time.Now()
+	limiter := &RateLimiter{}
+
+	if limit.RequestsPerSecond > 0 {
+		burst := max(limit.Burst, 1)
+		limiter.buckets = append(limiter.buckets, newTokenBucket(limit.RequestsPerSecond, float64(burst), now))
+	}
+	if limit.PerMinute > 0 {
+		limiter.buckets = append(limiter.buckets, newTokenBucket(float64(limit.PerMinute)/60, float64(limit.PerMinute), now))
+	}
+
+	if len(limiter.buckets) == 0 && sleepMillis > 0 {
+		limiter.buckets = append(limiter.buckets, newTokenBucket(1000/float64(sleepMillis), 1, now))
+	}
+
+	if len(limiter.buckets) == 0 {
+		return nil
+	}
+	return limiter
+}
+
+func newTokenBucket(rate, capacity float64, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, capacity: capacity, tokens: capacity, last: now}
+}
+
+// Wait blocks until the request is allowed to go out or ctx is done.
+// A nil limiter never blocks.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	return util.SleepContext(ctx, l.reserve(time.Now()))
+}
+
+// reserve takes a token from every bucket and returns how long the caller
+// has to wait before the reservation is honoured.
+func (l *RateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var delay time.Duration
+	for _, bucket := range l.buckets {
+		delay = max(delay, bucket.reserve(now))
+	}
+	return delay
+}
+
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
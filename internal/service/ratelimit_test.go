@@ -0,0 +1,113 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	tests := []struct {
+		name            string
+		limit           model.RateLimit
+		sleepMillis     int
+		expectedBuckets int
+	}{
+		{"Nothing configured", model.RateLimit{}, 0, 0},
+		{"Falls back to sleep", model.RateLimit{}, 500, 1},
+		{"Requests per second", model.RateLimit{RequestsPerSecond: 50, Burst: 10}, 1000, 1},
+		{"Per second and per minute", model.RateLimit{RequestsPerSecond: 50, PerMinute: 1000}, 0, 2},
+		{"Per minute only", model.RateLimit{PerMinute: 60}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(tt.limit, tt.sleepMillis)
+
+			if tt.expectedBuckets == 0 {
+				if limiter != nil {
+					t.Errorf("Expected nil limiter, got %d buckets", len(limiter.buckets))
+				}
+				return
+			}
+
+			if limiter == nil {
+				t.Fatal("Expected a limiter, got nil")
+			}
+			if len(limiter.buckets) != tt.expectedBuckets {
+				t.Errorf("Expected %d buckets, got %d", tt.expectedBuckets, len(limiter.buckets))
+			}
+		})
+	}
+}
+
+func TestRateLimiter_reserve_Burst(t *testing.T) {
+	start := time.Now()
+	limiter := NewRateLimiter(model.RateLimit{RequestsPerSecond: 10, Burst: 3}, 0)
+
+	// The burst goes out immediately
+	for i := 0; i < 3; i++ {
+		if delay := limiter.reserve(start); delay != 0 {
+			t.Fatalf("Request %d: expected no delay within burst, got %v", i, delay)
+		}
+	}
+
+	// Then requests are spaced at the configured rate
+	if delay := limiter.reserve(start); delay != 100*time.Millisecond {
+		t.Errorf("Expected 100ms delay, got %v", delay)
+	}
+	if delay := limiter.reserve(start); delay != 200*time.Millisecond {
+		t.Errorf("Expected 200ms delay, got %v", delay)
+	}
+
+	// Tokens refill over time
+	if delay := limiter.reserve(start.Add(time.Second)); delay != 0 {
+		t.Errorf("Expected no delay after refill, got %v", delay)
+	}
+}
+
+func TestRateLimiter_reserve_StrictestBucketWins(t *testing.T) {
+	start := time.Now()
+	limiter := NewRateLimiter(model.RateLimit{RequestsPerSecond: 100, Burst: 100, PerMinute: 2}, 0)
+
+	limiter.reserve(start)
+	limiter.reserve(start)
+
+	delay := limiter.reserve(start)
+	if delay != 30*time.Second {
+		t.Errorf("Expected the per-minute quota to impose a 30s delay, got %v", delay)
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	var nilLimiter *RateLimiter
+	if err := nilLimiter.Wait(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	limiter := NewRateLimiter(model.RateLimit{RequestsPerSecond: 20, Burst: 1}, 0)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	if elapsed < 90*time.Millisecond {
+		t.Errorf("Expected at least ~100ms for 3 requests at 20 rps, took %v", elapsed)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("Rate limiter waited too long: %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := NewRateLimiter(model.RateLimit{PerMinute: 1}, 0)
+	slow.Wait(ctx)
+	if err := slow.Wait(ctx); err == nil {
+		t.Error("Expected Wait to return the context error")
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ResultsSuffix is appended to the input file path for the JSON Lines result log.
+const ResultsSuffix = ".results.jsonl"
+
+const redactedValue = "REDACTED"
+
+// defaultRedactHeaders are masked when redact_headers is not configured.
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// resultLine is one line of the result log.
+type resultLine struct {
+	Index           int                 `json:"index"`
+	Row             []string            `json:"row"`
+	Method          string              `json:"method"`
+	URL             string              `json:"url"`
+	RequestHeaders  map[string][]string `json:"request_headers"`
+	Result          string              `json:"result"`
+	Status          int                 `json:"status"`
+	ResponseHeaders map[string][]string `json:"response_headers"`
+	Body            string              `json:"body"`
+	LatencyMillis   float64             `json:"latency_ms"`
+	Attempts        int                 `json:"attempts"`
+	Error           string              `json:"error,omitempty"`
+}
+
+// encodeResultLine renders the response as a single JSON line, masking the
+// values of the redacted headers.
+func encodeResultLine(index int, response model.Response, redact []string) ([]byte, error) {
+	line := resultLine{
+		Index:           index,
+		Row:             response.Row,
+		Method:          response.Method,
+		URL:             response.URL,
+		RequestHeaders:  redactHeaders(response.RequestHeaders, redact),
+		Result:          response.Type.String(),
+		Status:          response.Status,
+		ResponseHeaders: redactHeaders(response.Headers, redact),
+		Body:            string(response.Body),
+		LatencyMillis:   float64(response.Latency) / float64(time.Millisecond),
+		Attempts:        response.Attempts,
+		Error:           response.Error,
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// redactHeaders returns a copy of header whose redacted values are masked.
+func redactHeaders(header http.Header, redact []string) map[string][]string {
+	if header == nil {
+		return map[string][]string{}
+	}
+	redacted := make(map[string][]string, len(header))
+	for name, values := range header {
+		if slices.ContainsFunc(redact, func(r string) bool { return strings.EqualFold(r, name) }) {
+			masked := make([]string, len(values))
+			for i := range masked {
+				masked[i] = redactedValue
+			}
+			redacted[name] = masked
+			continue
+		}
+		redacted[name] = values
+	}
+	return redacted
+}
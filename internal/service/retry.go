@@ -0,0 +1,117 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"slices"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultBaseBackoff = 500 * time.Millisecond
+	defaultMaxBackoff  = 30 * time.Second
+)
+
+// Error kinds that can be listed in retry_on_errors.
+const (
+	errorKindTimeout    = "timeout"
+	errorKindConnection = "connection"
+	errorKindDNS        = "dns"
+	errorKindTLS        = "tls"
+	errorKindOther      = "other"
+)
+
+// defaultRetryStatuses are retried when retry_on_status is not configured.
+var defaultRetryStatuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// retryPolicy applies model.RetryPolicy with its defaults filled in.
+type retryPolicy struct {
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	jitter      float64
+	statuses    []int
+	errorKinds  []string
+}
+
+func newRetryPolicy(conf model.RetryPolicy) retryPolicy {
+	policy := retryPolicy{
+		maxAttempts: max(conf.MaxAttempts, 1),
+		baseBackoff: time.Duration(conf.BaseBackoffMillis) * time.Millisecond,
+		maxBackoff:  time.Duration(conf.MaxBackoffMillis) * time.Millisecond,
+		jitter:      min(max(conf.Jitter, 0), 1),
+		statuses:    conf.RetryOnStatus,
+		errorKinds:  conf.RetryOnErrors,
+	}
+	if policy.baseBackoff <= 0 {
+		policy.baseBackoff = defaultBaseBackoff
+	}
+	if policy.maxBackoff <= 0 {
+		policy.maxBackoff = defaultMaxBackoff
+	}
+	if policy.statuses == nil {
+		policy.statuses = defaultRetryStatuses
+	}
+	return policy
+}
+
+// shouldRetry reports whether another attempt is allowed after the given outcome.
+func (p retryPolicy) shouldRetry(status int, err error, attempt int) bool {
+	if attempt >= p.maxAttempts {
+		return false
+	}
+	if err != nil {
+		return p.isRetryableError(err)
+	}
+	return slices.Contains(p.statuses, status)
+}
+
+// isRetryableError checks the error kind against retry_on_errors.
+// When no kinds are configured every transport error is retried.
+func (p retryPolicy) isRetryableError(err error) bool {
+	if len(p.errorKinds) == 0 {
+		return true
+	}
+	return slices.Contains(p.errorKinds, errorKind(err))
+}
+
+// backoff returns the exponential delay before the next attempt,
+// capped at the max backoff and reduced by a random jitter fraction.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(p.baseBackoff) * math.Pow(2, float64(attempt-1))
+	delay = min(delay, float64(p.maxBackoff))
+	delay -= delay * p.jitter * rand.Float64()
+	return time.Duration(delay)
+}
+
+// errorKind classifies a transport error so it can be matched against retry_on_errors.
+func errorKind(err error) string {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var unknownAuthErr x509.UnknownAuthorityError
+	var opErr *net.OpError
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errorKindTimeout
+	case errors.As(err, &dnsErr):
+		return errorKindDNS
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &unknownAuthErr):
+		return errorKindTLS
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &opErr):
+		return errorKindConnection
+	}
+	return errorKindOther
+}
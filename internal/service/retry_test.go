@@ -0,0 +1,106 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNewRetryPolicy_Defaults(t *testing.T) {
+	policy := newRetryPolicy(model.RetryPolicy{})
+
+	if policy.maxAttempts != 1 {
+		t.Errorf("Expected 1 attempt by default, got %d", policy.maxAttempts)
+	}
+	if policy.baseBackoff != defaultBaseBackoff {
+		t.Errorf("Expected base backoff %v, got %v", defaultBaseBackoff, policy.baseBackoff)
+	}
+	if policy.maxBackoff != defaultMaxBackoff {
+		t.Errorf("Expected max backoff %v, got %v", defaultMaxBackoff, policy.maxBackoff)
+	}
+	if len(policy.statuses) != len(defaultRetryStatuses) {
+		t.Errorf("Expected default retry statuses, got %v", policy.statuses)
+	}
+}
+
+func TestRetryPolicy_shouldRetry(t *testing.T) {
+	policy := newRetryPolicy(model.RetryPolicy{
+		MaxAttempts:   3,
+		RetryOnStatus: []int{500, 503},
+		RetryOnErrors: []string{"timeout", "connection"},
+	})
+
+	tests := []struct {
+		name     string
+		status   int
+		err      error
+		attempt  int
+		expected bool
+	}{
+		{"Retryable status", 503, nil, 1, true},
+		{"Non retryable status", 404, nil, 1, false},
+		{"Success", 200, nil, 1, false},
+		{"Attempts exhausted", 500, nil, 3, false},
+		{"Timeout error", 0, context.DeadlineExceeded, 2, true},
+		{"Connection refused", 0, fmt.Errorf("dial: %w", syscall.ECONNREFUSED), 1, true},
+		{"Error kind not listed", 0, &net.DNSError{Err: "no such host", Name: "x"}, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.shouldRetry(tt.status, tt.err, tt.attempt); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := newRetryPolicy(model.RetryPolicy{BaseBackoffMillis: 100, MaxBackoffMillis: 1000})
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, want := range expected {
+		if got := policy.backoff(i + 1); got != want {
+			t.Errorf("Attempt %d: expected backoff %v, got %v", i+1, want, got)
+		}
+	}
+}
+
+func TestRetryPolicy_backoff_Jitter(t *testing.T) {
+	policy := newRetryPolicy(model.RetryPolicy{BaseBackoffMillis: 1000, Jitter: 0.5})
+
+	for i := 0; i < 50; i++ {
+		delay := policy.backoff(1)
+		if delay < 500*time.Millisecond || delay > time.Second {
+			t.Fatalf("Backoff with 50%% jitter out of range: %v", delay)
+		}
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"Deadline exceeded", fmt.Errorf("wrapped: %w", context.DeadlineExceeded), errorKindTimeout},
+		{"DNS error", &net.DNSError{Err: "no such host", Name: "example.invalid"}, errorKindDNS},
+		{"Connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, errorKindConnection},
+		{"Unexpected EOF", io.ErrUnexpectedEOF, errorKindConnection},
+		{"Unknown error", errors.New("something else"), errorKindOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorKind(tt.err); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"batchRequestsRecover/internal/util"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	retryAfterScopeGlobal = "global"
+
+	defaultRetryAfterMaxWait     = 5 * time.Minute
+	defaultRetryAfterMaxAttempts = 5
+)
+
+// pauseGate holds back every worker until a run-wide pause has elapsed.
+type pauseGate struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// extend pushes the end of the pause to now+wait, never shortening it.
+func (g *pauseGate) extend(wait time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if until := time.Now().Add(wait); until.After(g.until) {
+		g.until = until
+	}
+}
+
+// wait blocks until the current pause, if any, is over or ctx is done.
+func (g *pauseGate) wait(ctx context.Context) error {
+	g.mu.Lock()
+	remaining := time.Until(g.until)
+	g.mu.Unlock()
+	return util.SleepContext(ctx, remaining)
+}
+
+// retryAfterDelay returns how long to wait before re-sending a record that
+// was answered with 429 or 503 and a Retry-After header. It reports false
+// when the response must not be re-sent: honouring is disabled, the status
+// does not qualify, the header is missing or the attempts are exhausted.
+func retryAfterDelay(conf model.RetryAfter, status int, header http.Header, throttled int) (time.Duration, bool) {
+	if conf.Disabled || (status != http.StatusTooManyRequests && status != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+
+	maxAttempts := conf.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultRetryAfterMaxAttempts
+	}
+	if throttled >= maxAttempts {
+		return 0, false
+	}
+
+	wait, ok := parseRetryAfter(header.Get("Retry-After"), time.Now())
+	if !ok {
+		return 0, false
+	}
+
+	maxWait := time.Duration(conf.MaxWaitMillis) * time.Millisecond
+	if maxWait <= 0 {
+		maxWait = defaultRetryAfterMaxWait
+	}
+	return min(wait, maxWait), true
+}
+
+// parseRetryAfter reads a Retry-After value, either delay-seconds or an HTTP-date.
+// Dates in the past yield a zero wait.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"Seconds", "120", 2 * time.Minute, true},
+		{"Seconds with spaces", " 3 ", 3 * time.Second, true},
+		{"Zero seconds", "0", 0, true},
+		{"HTTP date in the future", "Tue, 02 Jan 2024 15:04:35 GMT", 30 * time.Second, true},
+		{"HTTP date in the past", "Tue, 02 Jan 2024 15:00:00 GMT", 0, true},
+		{"Empty", "", 0, false},
+		{"Negative seconds", "-5", 0, false},
+		{"Garbage", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if wait != tt.expected {
+				t.Errorf("Expected wait %v, got %v", tt.expected, wait)
+			}
+		})
+	}
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	withHeader := http.Header{"Retry-After": []string{"600"}}
+
+	tests := []struct {
+		name      string
+		conf      model.RetryAfter
+		status    int
+		header    http.Header
+		throttled int
+		expected  time.Duration
+		ok        bool
+	}{
+		{"429 with header", model.RetryAfter{}, 429, withHeader, 0, defaultRetryAfterMaxWait, true},
+		{"503 with header", model.RetryAfter{MaxWaitMillis: 1000000}, 503, withHeader, 0, 10 * time.Minute, true},
+		{"Capped by max wait", model.RetryAfter{MaxWaitMillis: 2000}, 429, withHeader, 0, 2 * time.Second, true},
+		{"Missing header", model.RetryAfter{}, 429, http.Header{}, 0, 0, false},
+		{"Other status", model.RetryAfter{}, 500, withHeader, 0, 0, false},
+		{"Disabled", model.RetryAfter{Disabled: true}, 429, withHeader, 0, 0, false},
+		{"Attempts exhausted", model.RetryAfter{MaxAttempts: 2}, 429, withHeader, 2, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, ok := retryAfterDelay(tt.conf, tt.status, tt.header, tt.throttled)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if wait != tt.expected {
+				t.Errorf("Expected wait %v, got %v", tt.expected, wait)
+			}
+		})
+	}
+}
+
+func TestPauseGate(t *testing.T) {
+	gate := &pauseGate{}
+
+	start := time.Now()
+	gate.wait(context.Background())
+	if time.Since(start) > 10*time.Millisecond {
+		t.Error("An idle gate should not block")
+	}
+
+	gate.extend(50 * time.Millisecond)
+	gate.extend(10 * time.Millisecond) // must not shorten the pause
+
+	start = time.Now()
+	gate.wait(context.Background())
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected to wait for the pause, waited only %v", elapsed)
+	}
+}
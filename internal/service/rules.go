@@ -0,0 +1,253 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultSuccessRules apply when no success rule is configured.
+var defaultSuccessRules = []model.ResponseRule{{Status: []string{"2xx"}}}
+
+// responseClassifier sorts the responses into success, skipped and error,
+// and holds the assertions successful responses must pass.
+type responseClassifier struct {
+	success    []responseRule
+	skip       []responseRule
+	assertions *responseAssertions
+}
+
+type responseRule struct {
+	statuses []statusRange
+	contains string
+	regex    *regexp.Regexp
+	path     jsonPath
+	equals   any
+	// hasEquals tells an explicit null apart from a missing json_equals
+	hasEquals bool
+}
+
+type statusRange struct {
+	min, max int
+}
+
+func newResponseClassifier(config model.Config) (*responseClassifier, error) {
+	successRules := config.Success
+	if len(successRules) == 0 {
+		successRules = defaultSuccessRules
+	}
+	success, err := compileRules(successRules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid success rule: %w", err)
+	}
+	skip, err := compileRules(config.Skip)
+	if err != nil {
+		return nil, fmt.Errorf("invalid skip rule: %w", err)
+	}
+	assertions, err := newResponseAssertions(config)
+	if err != nil {
+		return nil, err
+	}
+	return &responseClassifier{success: success, skip: skip, assertions: assertions}, nil
+}
+
+// classify checks the skip rules first, so a rule can set aside responses
+// that would otherwise count as a success.
+func (c *responseClassifier) classify(status int, body []byte) model.ResponseType {
+	parsed := &responseBody{raw: body}
+	for _, rule := range c.skip {
+		if rule.matches(status, parsed) {
+			return model.SKIPPED
+		}
+	}
+	for _, rule := range c.success {
+		if rule.matches(status, parsed) {
+			return model.SUCCESS
+		}
+	}
+	return model.ERROR
+}
+
+func compileRules(rules []model.ResponseRule) ([]responseRule, error) {
+	compiled := make([]responseRule, 0, len(rules))
+	for i, rule := range rules {
+		c, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+func compileRule(rule model.ResponseRule) (responseRule, error) {
+	var compiled responseRule
+	for _, status := range rule.Status {
+		statuses, err := parseStatusRange(status)
+		if err != nil {
+			return compiled, err
+		}
+		compiled.statuses = append(compiled.statuses, statuses)
+	}
+
+	compiled.contains = rule.BodyContains
+	if rule.BodyRegex != "" {
+		regex, err := regexp.Compile(rule.BodyRegex)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid body_regex: %w", err)
+		}
+		compiled.regex = regex
+	}
+
+	if rule.JSONPath != "" {
+		path, err := parseJSONPath(rule.JSONPath)
+		if err != nil {
+			return compiled, err
+		}
+		compiled.path = path
+	}
+	if rule.JSONEquals != nil {
+		if rule.JSONPath == "" {
+			return compiled, errors.New("json_equals requires json_path")
+		}
+		if err := decodeJSON(rule.JSONEquals, &compiled.equals); err != nil {
+			return compiled, fmt.Errorf("invalid json_equals: %w", err)
+		}
+		compiled.hasEquals = true
+	}
+
+	if len(rule.Status) == 0 && rule.BodyContains == "" && rule.BodyRegex == "" && rule.JSONPath == "" {
+		return compiled, errors.New("rule has no conditions")
+	}
+	return compiled, nil
+}
+
+// parseStatusRange accepts "404", "400-499" and "4xx".
+func parseStatusRange(value string) (statusRange, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 3 && strings.EqualFold(value[1:], "xx") && value[0] >= '1' && value[0] <= '5' {
+		class := int(value[0]-'0') * 100
+		return statusRange{min: class, max: class + 99}, nil
+	}
+	if low, high, ok := strings.Cut(value, "-"); ok {
+		minStatus, errMin := strconv.Atoi(strings.TrimSpace(low))
+		maxStatus, errMax := strconv.Atoi(strings.TrimSpace(high))
+		if errMin != nil || errMax != nil || minStatus > maxStatus {
+			return statusRange{}, fmt.Errorf("invalid status range %q", value)
+		}
+		return statusRange{min: minStatus, max: maxStatus}, nil
+	}
+	status, err := strconv.Atoi(value)
+	if err != nil {
+		return statusRange{}, fmt.Errorf("invalid status %q", value)
+	}
+	return statusRange{min: status, max: status}, nil
+}
+
+func (r responseRule) matches(status int, body *responseBody) bool {
+	if len(r.statuses) > 0 && !r.matchesStatus(status) {
+		return false
+	}
+	if r.contains != "" && !bytes.Contains(body.raw, []byte(r.contains)) {
+		return false
+	}
+	if r.regex != nil && !r.regex.Match(body.raw) {
+		return false
+	}
+	if r.path != nil {
+		doc, ok := body.json()
+		if !ok {
+			return false
+		}
+		value, found := r.path.lookup(doc)
+		if !found || (r.hasEquals && !jsonEqual(value, r.equals)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r responseRule) matchesStatus(status int) bool {
+	for _, statuses := range r.statuses {
+		if status >= statuses.min && status <= statuses.max {
+			return true
+		}
+	}
+	return false
+}
+
+// responseBody decodes the body as JSON only when a rule needs it, and at most once.
+type responseBody struct {
+	raw    []byte
+	parsed bool
+	doc    any
+	valid  bool
+}
+
+func (b *responseBody) json() (any, bool) {
+	if !b.parsed {
+		b.parsed = true
+		b.valid = decodeJSON(b.raw, &b.doc) == nil
+	}
+	return b.doc, b.valid
+}
+
+// decodeJSON decodes a JSON document, keeping numbers as json.Number so
+// IDs above 2^53 are not rounded.
+func decodeJSON(data []byte, doc *any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(doc); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("invalid data after the JSON value")
+	}
+	return nil
+}
+
+// jsonEqual compares two decoded JSON values, numbers by their value, so 1
+// equals 1.0 and 9007199254740993 differs from 9007199254740992.
+func jsonEqual(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		x, okA := new(big.Rat).SetString(a.String())
+		number, isNumber := b.(json.Number)
+		if !isNumber || !okA {
+			return false
+		}
+		y, okB := new(big.Rat).SetString(number.String())
+		return okB && x.Cmp(y) == 0
+	case map[string]any:
+		object, ok := b.(map[string]any)
+		if !ok || len(a) != len(object) {
+			return false
+		}
+		for key, value := range a {
+			other, found := object[key]
+			if !found || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		array, ok := b.([]any)
+		if !ok || len(a) != len(array) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], array[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
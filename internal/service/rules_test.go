@@ -0,0 +1,184 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestParseStatusRange(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected statusRange
+		wantErr  bool
+	}{
+		{value: "409", expected: statusRange{409, 409}},
+		{value: "200-299", expected: statusRange{200, 299}},
+		{value: "4xx", expected: statusRange{400, 499}},
+		{value: "5XX", expected: statusRange{500, 599}},
+		{value: "299-200", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "9xx", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseStatusRange(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResponseClassifier_Rules(t *testing.T) {
+	config := model.Config{
+		Success: []model.ResponseRule{
+			{Status: []string{"2xx"}},
+			{Status: []string{"409"}, BodyContains: "already exists"},
+		},
+		Skip: []model.ResponseRule{
+			{Status: []string{"404"}},
+			{Status: []string{"200"}, JSONPath: "$.result", JSONEquals: json.RawMessage(`"IGNORED"`)},
+			{BodyRegex: `(?i)duplicate key`},
+		},
+	}
+	classifier, err := newResponseClassifier(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected model.ResponseType
+	}{
+		{name: "2xx is success", status: 201, body: `{"id":1}`, expected: model.SUCCESS},
+		{name: "409 with message is success", status: 409, body: "resource already exists", expected: model.SUCCESS},
+		{name: "409 without message is error", status: 409, body: "conflict", expected: model.ERROR},
+		{name: "404 is skipped", status: 404, body: "", expected: model.SKIPPED},
+		{name: "skip wins over success", status: 200, body: `{"result":"IGNORED"}`, expected: model.SKIPPED},
+		{name: "json value differs", status: 200, body: `{"result":"DONE"}`, expected: model.SUCCESS},
+		{name: "body regex on any status", status: 500, body: "ERROR: Duplicate key", expected: model.SKIPPED},
+		{name: "500 is error", status: 500, body: "boom", expected: model.ERROR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifier.classify(tt.status, []byte(tt.body)); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResponseClassifier_JSONPathExists(t *testing.T) {
+	classifier, err := newResponseClassifier(model.Config{
+		Success: []model.ResponseRule{{JSONPath: "$.data.id"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := classifier.classify(500, []byte(`{"data":{"id":null}}`)); got != model.SUCCESS {
+		t.Errorf("A present value should match, got %v", got)
+	}
+	if got := classifier.classify(200, []byte(`{"data":{}}`)); got != model.ERROR {
+		t.Errorf("A missing value should not match, got %v", got)
+	}
+	if got := classifier.classify(200, []byte(`not json`)); got != model.ERROR {
+		t.Errorf("A non JSON body should not match, got %v", got)
+	}
+}
+
+func TestResponseClassifier_JSONEqualsNumbers(t *testing.T) {
+	classifier, err := newResponseClassifier(model.Config{
+		Success: []model.ResponseRule{{JSONPath: "$.id", JSONEquals: json.RawMessage(`9007199254740993`)}},
+		Skip:    []model.ResponseRule{{JSONPath: "$.total", JSONEquals: json.RawMessage(`[1.5, {"n": 10}]`)}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		body     string
+		expected model.ResponseType
+	}{
+		{body: `{"id":9007199254740993}`, expected: model.SUCCESS},
+		{body: `{"id":9007199254740992}`, expected: model.ERROR},
+		{body: `{"id":"9007199254740993"}`, expected: model.ERROR},
+		{body: `{"total":[1.50, {"n": 1e1}]}`, expected: model.SKIPPED},
+		{body: `{"id":9007199254740993} trailing`, expected: model.ERROR},
+	}
+	for _, tt := range tests {
+		if got := classifier.classify(500, []byte(tt.body)); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.body, tt.expected, got)
+		}
+	}
+}
+
+func TestNewResponseClassifier_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config model.Config
+	}{
+		{name: "bad status", config: model.Config{Success: []model.ResponseRule{{Status: []string{"2zz"}}}}},
+		{name: "bad regex", config: model.Config{Skip: []model.ResponseRule{{BodyRegex: "("}}}},
+		{name: "bad json path", config: model.Config{Skip: []model.ResponseRule{{JSONPath: "$.a["}}}},
+		{name: "equals without path", config: model.Config{Skip: []model.ResponseRule{{JSONEquals: json.RawMessage(`1`)}}}},
+		{name: "empty rule", config: model.Config{Success: []model.ResponseRule{{}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newResponseClassifier(tt.config); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestProcessService_ProcessAll_InvalidRules(t *testing.T) {
+	called := false
+	service := &ProcessService{
+		config: model.Config{Success: []model.ResponseRule{{BodyRegex: "("}}},
+		httpService: &MockHttpService{callFunc: func(record http.Request) ([]byte, int, error) {
+			called = true
+			return nil, 200, nil
+		}},
+	}
+
+	err := service.ProcessAll(context.Background(), recordsOf([]http.Request{*createTestRequest("https://api.example.com")}), &memoryWriter{})
+	if err == nil {
+		t.Fatal("Expected an error for an invalid rule")
+	}
+	if called {
+		t.Error("No request should be sent with invalid rules")
+	}
+}
+
+func TestProcessService_processRecord_Skipped(t *testing.T) {
+	service := &ProcessService{
+		config: model.Config{Skip: []model.ResponseRule{{Status: []string{"404"}}}},
+		httpService: &MockHttpService{callFunc: func(record http.Request) ([]byte, int, error) {
+			return []byte("gone"), 404, nil
+		}},
+	}
+
+	response, err := service.processRecord(context.Background(), *createTestRequest("https://api.example.com"), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.Type != model.SKIPPED {
+		t.Errorf("Expected SKIPPED, got %v", response.Type)
+	}
+	if response.Message != "3-404 [attempts=1] - gone" {
+		t.Errorf("Unexpected message %q", response.Message)
+	}
+}
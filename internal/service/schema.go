@@ -0,0 +1,392 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonSchema is the subset of JSON Schema used to validate response bodies:
+// type, enum, const, the numeric, string and array bounds, pattern,
+// properties, required, additionalProperties, items, allOf, anyOf, oneOf,
+// not and local $ref into $defs or definitions. Other keywords are ignored.
+type jsonSchema struct {
+	// boolean holds the value of a schema written as true or false
+	boolean *bool
+
+	Type                 schemaTypes            `json:"type"`
+	Enum                 []json.RawMessage      `json:"enum"`
+	Const                json.RawMessage        `json:"const"`
+	Minimum              *json.Number           `json:"minimum"`
+	Maximum              *json.Number           `json:"maximum"`
+	ExclusiveMinimum     *json.Number           `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *json.Number           `json:"exclusiveMaximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	Items                *jsonSchema            `json:"items"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties"`
+	AllOf                []*jsonSchema          `json:"allOf"`
+	AnyOf                []*jsonSchema          `json:"anyOf"`
+	OneOf                []*jsonSchema          `json:"oneOf"`
+	Not                  *jsonSchema            `json:"not"`
+	Ref                  string                 `json:"$ref"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+	Definitions          map[string]*jsonSchema `json:"definitions"`
+
+	pattern *regexp.Regexp
+	constV  any
+	enumV   []any
+}
+
+// schemaTypes accepts both "type": "string" and "type": ["string", "null"].
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings")
+	}
+	*t = list
+	return nil
+}
+
+func (s *jsonSchema) UnmarshalJSON(data []byte) error {
+	var boolean bool
+	if err := json.Unmarshal(data, &boolean); err == nil {
+		s.boolean = &boolean
+		return nil
+	}
+	// the alias drops this method, so the fields are decoded normally
+	type plain jsonSchema
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// loadJSONSchema reads and compiles the schema file.
+func loadJSONSchema(path string) (*jsonSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema: %w", err)
+	}
+	return parseJSONSchema(data)
+}
+
+func parseJSONSchema(data []byte) (*jsonSchema, error) {
+	schema := &jsonSchema{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("error parsing schema: %w", err)
+	}
+	if err := schema.compile(schema); err != nil {
+		return nil, fmt.Errorf("error compiling schema: %w", err)
+	}
+	return schema, nil
+}
+
+// compile prepares the patterns and constants and checks that every
+// $ref resolves, walking the whole schema once.
+func (s *jsonSchema) compile(root *jsonSchema) error {
+	if s == nil || s.boolean != nil {
+		return nil
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+	if s.Const != nil {
+		if err := decodeJSON(s.Const, &s.constV); err != nil {
+			return fmt.Errorf("invalid const: %w", err)
+		}
+	}
+	for _, raw := range s.Enum {
+		var value any
+		if err := decodeJSON(raw, &value); err != nil {
+			return fmt.Errorf("invalid enum: %w", err)
+		}
+		s.enumV = append(s.enumV, value)
+	}
+	for _, bound := range []*json.Number{s.Minimum, s.Maximum, s.ExclusiveMinimum, s.ExclusiveMaximum} {
+		if bound != nil {
+			if _, ok := new(big.Rat).SetString(bound.String()); !ok {
+				return fmt.Errorf("invalid bound %s", *bound)
+			}
+		}
+	}
+	if s.Ref != "" {
+		target, err := root.resolve(s.Ref)
+		if err != nil {
+			return err
+		}
+		// validation would recurse forever on the same value
+		if target.reaches(root, s, make(map[*jsonSchema]bool)) {
+			return fmt.Errorf("$ref %q refers back to itself without going into a property or an item", s.Ref)
+		}
+	}
+
+	children := []*jsonSchema{s.Items, s.AdditionalProperties, s.Not}
+	children = append(children, s.AllOf...)
+	children = append(children, s.AnyOf...)
+	children = append(children, s.OneOf...)
+	for _, group := range []map[string]*jsonSchema{s.Properties, s.Defs, s.Definitions} {
+		for _, child := range group {
+			children = append(children, child)
+		}
+	}
+	for _, child := range children {
+		if err := child.compile(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reaches tells whether target is s, or a schema s applies to the same
+// value through $ref, allOf, anyOf, oneOf or not.
+func (s *jsonSchema) reaches(root, target *jsonSchema, visited map[*jsonSchema]bool) bool {
+	if s == nil || s.boolean != nil || visited[s] {
+		return false
+	}
+	if s == target {
+		return true
+	}
+	visited[s] = true
+
+	next := []*jsonSchema{s.Not}
+	next = append(next, s.AllOf...)
+	next = append(next, s.AnyOf...)
+	next = append(next, s.OneOf...)
+	if s.Ref != "" {
+		if resolved, err := root.resolve(s.Ref); err == nil {
+			next = append(next, resolved)
+		}
+	}
+	return slices.ContainsFunc(next, func(schema *jsonSchema) bool {
+		return schema.reaches(root, target, visited)
+	})
+}
+
+func (s *jsonSchema) resolve(ref string) (*jsonSchema, error) {
+	if ref == "#" {
+		return s, nil
+	}
+	for _, prefix := range []string{"#/$defs/", "#/definitions/"} {
+		name, ok := strings.CutPrefix(ref, prefix)
+		if !ok {
+			continue
+		}
+		defs := s.Defs
+		if prefix == "#/definitions/" {
+			defs = s.Definitions
+		}
+		if target, ok := defs[name]; ok {
+			return target, nil
+		}
+	}
+	return nil, fmt.Errorf("unresolved $ref %q", ref)
+}
+
+// validate checks the JSON value decoded by decodeJSON against the schema,
+// returning the first violation found along with its location in the document.
+func (s *jsonSchema) validate(value any) error {
+	return s.validateAt(s, value, "$")
+}
+
+func (s *jsonSchema) validateAt(root *jsonSchema, value any, at string) error {
+	if s == nil {
+		return nil
+	}
+	if s.boolean != nil {
+		if !*s.boolean {
+			return fmt.Errorf("%s: no value is allowed here", at)
+		}
+		return nil
+	}
+	if s.Ref != "" {
+		target, err := root.resolve(s.Ref)
+		if err != nil {
+			return err
+		}
+		if err := target.validateAt(root, value, at); err != nil {
+			return err
+		}
+	}
+
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return hasSchemaType(value, t) }) {
+		return fmt.Errorf("%s: expected type %s, got %s", at, strings.Join(s.Type, " or "), schemaTypeOf(value))
+	}
+	if s.Enum != nil && !slices.ContainsFunc(s.enumV, func(e any) bool { return jsonEqual(e, value) }) {
+		return fmt.Errorf("%s: value %s is not one of the allowed values", at, formatJSONValue(value))
+	}
+	if s.Const != nil && !jsonEqual(s.constV, value) {
+		return fmt.Errorf("%s: expected %s, got %s", at, string(s.Const), formatJSONValue(value))
+	}
+
+	switch typed := value.(type) {
+	case json.Number:
+		if err := s.validateNumber(typed, at); err != nil {
+			return err
+		}
+	case string:
+		length := utf8.RuneCountInString(typed)
+		if s.MinLength != nil && length < *s.MinLength {
+			return fmt.Errorf("%s: length %d is shorter than %d", at, length, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fmt.Errorf("%s: length %d is longer than %d", at, length, *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(typed) {
+			return fmt.Errorf("%s: %q does not match pattern %q", at, typed, s.Pattern)
+		}
+	case []any:
+		if s.MinItems != nil && len(typed) < *s.MinItems {
+			return fmt.Errorf("%s: %d items, expected at least %d", at, len(typed), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(typed) > *s.MaxItems {
+			return fmt.Errorf("%s: %d items, expected at most %d", at, len(typed), *s.MaxItems)
+		}
+		for i, item := range typed {
+			if err := s.Items.validateAt(root, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		if err := s.validateObject(root, typed, at); err != nil {
+			return err
+		}
+	}
+
+	return s.validateCombinators(root, value, at)
+}
+
+func (s *jsonSchema) validateNumber(value json.Number, at string) error {
+	// compared as exact rationals, as floats would round big integers
+	compare := func(bound *json.Number) int {
+		x, _ := new(big.Rat).SetString(value.String())
+		y, _ := new(big.Rat).SetString(bound.String())
+		return x.Cmp(y)
+	}
+	switch {
+	case s.Minimum != nil && compare(s.Minimum) < 0:
+		return fmt.Errorf("%s: %s is less than the minimum %s", at, value, *s.Minimum)
+	case s.Maximum != nil && compare(s.Maximum) > 0:
+		return fmt.Errorf("%s: %s is greater than the maximum %s", at, value, *s.Maximum)
+	case s.ExclusiveMinimum != nil && compare(s.ExclusiveMinimum) <= 0:
+		return fmt.Errorf("%s: %s must be greater than %s", at, value, *s.ExclusiveMinimum)
+	case s.ExclusiveMaximum != nil && compare(s.ExclusiveMaximum) >= 0:
+		return fmt.Errorf("%s: %s must be less than %s", at, value, *s.ExclusiveMaximum)
+	}
+	return nil
+}
+
+func (s *jsonSchema) validateObject(root *jsonSchema, object map[string]any, at string) error {
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", at, name)
+		}
+	}
+
+	// sorted so the reported violation does not depend on map order
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := s.AdditionalProperties
+		if property, ok := s.Properties[name]; ok {
+			child = property
+		}
+		if err := child.validateAt(root, object[name], at+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *jsonSchema) validateCombinators(root *jsonSchema, value any, at string) error {
+	for _, sub := range s.AllOf {
+		if err := sub.validateAt(root, value, at); err != nil {
+			return err
+		}
+	}
+	if len(s.AnyOf) > 0 {
+		matched := slices.ContainsFunc(s.AnyOf, func(sub *jsonSchema) bool { return sub.validateAt(root, value, at) == nil })
+		if !matched {
+			return fmt.Errorf("%s: value does not match any of the anyOf schemas", at)
+		}
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, sub := range s.OneOf {
+			if sub.validateAt(root, value, at) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: value matches %d of the oneOf schemas, expected exactly one", at, matches)
+		}
+	}
+	if s.Not != nil && s.Not.validateAt(root, value, at) == nil {
+		return errors.New(at + ": value must not match the not schema")
+	}
+	return nil
+}
+
+func hasSchemaType(value any, schemaType string) bool {
+	switch schemaType {
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		rat, ok := new(big.Rat).SetString(number.String())
+		return ok && rat.IsInt()
+	}
+	return schemaTypeOf(value) == schemaType
+}
+
+func schemaTypeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// formatJSONValue renders a decoded value back as JSON for messages.
+func formatJSONValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
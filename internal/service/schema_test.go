@@ -0,0 +1,173 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"required": ["id", "status", "items"],
+	"properties": {
+		"id": {"type": "string", "pattern": "^ord-[0-9]+$"},
+		"status": {"enum": ["CREATED", "UPDATED"]},
+		"total": {"type": "number", "minimum": 0},
+		"items": {"type": "array", "minItems": 1, "items": {"$ref": "#/$defs/item"}},
+		"note": {"type": ["string", "null"], "maxLength": 5}
+	},
+	"additionalProperties": false,
+	"$defs": {
+		"item": {
+			"type": "object",
+			"required": ["sku", "qty"],
+			"properties": {"sku": {"type": "string"}, "qty": {"type": "integer", "exclusiveMinimum": 0}}
+		}
+	}
+}`
+
+func TestJSONSchema_Validate(t *testing.T) {
+	schema, err := parseJSONSchema([]byte(orderSchema))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "valid", body: `{"id":"ord-1","status":"CREATED","total":3.5,"items":[{"sku":"a","qty":2}],"note":null}`},
+		{name: "missing required", body: `{"id":"ord-1","items":[]}`, wantErr: `$: missing required property "status"`},
+		{name: "wrong type", body: `[]`, wantErr: "$: expected type object, got array"},
+		{name: "pattern", body: `{"id":"x","status":"CREATED","items":[{"sku":"a","qty":1}]}`, wantErr: `$.id: "x" does not match pattern`},
+		{name: "enum", body: `{"id":"ord-1","status":"FAILED","items":[{"sku":"a","qty":1}]}`, wantErr: `$.status: value "FAILED" is not one of the allowed values`},
+		{name: "min items", body: `{"id":"ord-1","status":"CREATED","items":[]}`, wantErr: "$.items: 0 items, expected at least 1"},
+		{name: "ref and integer", body: `{"id":"ord-1","status":"CREATED","items":[{"sku":"a","qty":1.5}]}`, wantErr: "$.items[0].qty: expected type integer, got number"},
+		{name: "exclusive minimum", body: `{"id":"ord-1","status":"CREATED","items":[{"sku":"a","qty":0}]}`, wantErr: "$.items[0].qty: 0 must be greater than 0"},
+		{name: "additional property", body: `{"id":"ord-1","status":"CREATED","items":[{"sku":"a","qty":1}],"extra":1}`, wantErr: "$.extra: no value is allowed here"},
+		{name: "big integer", body: `{"id":"ord-1","status":"CREATED","items":[{"sku":"a","qty":9007199254740993}]}`},
+		{name: "max length", body: `{"id":"ord-1","status":"CREATED","items":[{"sku":"a","qty":1}],"note":"too long"}`, wantErr: "$.note: length 8 is longer than 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc any
+			if err := decodeJSON([]byte(tt.body), &doc); err != nil {
+				t.Fatalf("Invalid test body: %v", err)
+			}
+			err := schema.validate(doc)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestJSONSchema_Combinators(t *testing.T) {
+	schema, err := parseJSONSchema([]byte(`{
+		"anyOf": [{"type": "string"}, {"type": "number"}],
+		"oneOf": [{"minimum": 10}, {"maximum": 20}],
+		"not": {"const": 15}
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for body, valid := range map[string]bool{`5`: true, `25`: true, `15`: false, `true`: false, `"text"`: false} {
+		var doc any
+		decodeJSON([]byte(body), &doc)
+		if err := schema.validate(doc); (err == nil) != valid {
+			t.Errorf("%s: expected valid %v, got %v", body, valid, err)
+		}
+	}
+}
+
+func TestJSONSchema_Numbers(t *testing.T) {
+	schema, err := parseJSONSchema([]byte(`{
+		"type": "integer",
+		"minimum": 9007199254740993,
+		"enum": [9007199254740993, 9007199254740995.0],
+		"not": {"const": 9007199254740995}
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for body, valid := range map[string]bool{
+		`9007199254740993`:   true,
+		`9007199254740992`:   false,
+		`9007199254740994`:   false,
+		`9007199254740995`:   false,
+		`9007199254740993.5`: false,
+	} {
+		var doc any
+		decodeJSON([]byte(body), &doc)
+		if err := schema.validate(doc); (err == nil) != valid {
+			t.Errorf("%s: expected valid %v, got %v", body, valid, err)
+		}
+	}
+}
+
+func TestParseJSONSchema_RefCycles(t *testing.T) {
+	cycles := map[string]string{
+		"root":       `{"$ref": "#"}`,
+		"definition": `{"$defs": {"a": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`,
+		"unused":     `{"type": "object", "$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"allOf": [{"$ref": "#/$defs/a"}]}}}`,
+		"combinator": `{"anyOf": [{"type": "string"}, {"not": {"$ref": "#"}}]}`,
+	}
+	for name, content := range cycles {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseJSONSchema([]byte(content))
+			if err == nil || !strings.Contains(err.Error(), "refers back to itself") {
+				t.Errorf("Expected the cycle to be rejected, got %v", err)
+			}
+		})
+	}
+
+	// recursion through a property or an item moves to a child value
+	tree, err := parseJSONSchema([]byte(`{
+		"type": "object",
+		"properties": {"children": {"type": "array", "items": {"$ref": "#"}}, "parent": {"$ref": "#/$defs/node"}},
+		"$defs": {"node": {"anyOf": [{"type": "null"}, {"$ref": "#"}]}}
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var value any
+	decodeJSON([]byte(`{"children": [{"children": [], "parent": null}, {"children": 1}]}`), &value)
+	if err := tree.validate(value); err == nil || !strings.Contains(err.Error(), "$.children[1].children") {
+		t.Errorf("Expected the nested violation, got %v", err)
+	}
+}
+
+func TestLoadJSONSchema_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"not json":       `{`,
+		"bad pattern":    `{"pattern": "("}`,
+		"unresolved ref": `{"items": {"$ref": "#/$defs/missing"}}`,
+		"bad type":       `{"type": 1}`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(name, " ", "_")+".json")
+			os.WriteFile(path, []byte(content), 0644)
+			if _, err := loadJSONSchema(path); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	if _, err := loadJSONSchema(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// compiledStep holds the templates and the extract columns of a step.
+// Parts without template actions are nil and sent as they are written.
+type compiledStep struct {
+	endpoint  *template.Template
+	headers   map[string]*template.Template
+	body      *template.Template
+	extractor *responseExtractor
+}
+
+// compileSteps checks the steps and compiles their templates, including the
+// ones of the config headers the steps send, and their extract columns.
+func compileSteps(steps []model.Step, headers map[string]string) ([]*compiledStep, error) {
+	compiled := make([]*compiledStep, len(steps))
+	names := make(map[string]bool, len(steps))
+	for i, step := range steps {
+		if step.Name == "" {
+			return nil, fmt.Errorf("step %d: name is required", i+1)
+		}
+		if names[step.Name] {
+			return nil, fmt.Errorf("step %d: duplicate name %q", i+1, step.Name)
+		}
+		names[step.Name] = true
+		if step.ApiEndpoint == "" {
+			return nil, fmt.Errorf("step %q: api_endpoint is required", step.Name)
+		}
+
+		var err error
+		c := &compiledStep{headers: make(map[string]*template.Template)}
+		if c.endpoint, err = parseTemplate("api_endpoint", step.ApiEndpoint); err != nil {
+			return nil, fmt.Errorf("step %q: %w", step.Name, err)
+		}
+		if c.body, err = parseTemplate("body", step.Body); err != nil {
+			return nil, fmt.Errorf("step %q: %w", step.Name, err)
+		}
+		for name, value := range stepHeaders(step, headers) {
+			header, err := parseTemplate("header "+name, value)
+			if err != nil {
+				return nil, fmt.Errorf("step %q: %w", step.Name, err)
+			}
+			if header != nil {
+				c.headers[name] = header
+			}
+		}
+		if c.extractor, err = newResponseExtractor(step.Extract); err != nil {
+			return nil, fmt.Errorf("step %q: %w", step.Name, err)
+		}
+		compiled[i] = c
+	}
+	return compiled, nil
+}
+
+// stepHeaders returns the config headers with the ones of the step on top.
+func stepHeaders(step model.Step, headers map[string]string) map[string]string {
+	merged := maps.Clone(headers)
+	if merged == nil {
+		merged = make(map[string]string, len(step.Headers))
+	}
+	maps.Copy(merged, step.Headers)
+	return merged
+}
+
+// processSteps sends the chain of steps for a row. Each step sees the row
+// values and the values extracted by the steps before it, and the chain
+// stops at the first step that is not a success. Its response, or the one
+// of the last step, is the result of the row. The success and skip rules
+// apply to every step, the assertions, the response schema and the extract
+// of the config only to the response of the last one.
+func (s *ProcessService) processSteps(ctx context.Context, rowValues map[string]string, index int) (model.Response, error) {
+	if err := s.compileResponseHandling(); err != nil {
+		return model.Response{Type: model.ERROR}, err
+	}
+
+	// the row values, and the values extracted by each step under its name
+	values := make(map[string]any, len(rowValues)+len(s.config.Steps))
+	for name, value := range rowValues {
+		values[name] = value
+	}
+	var res model.Response
+	for i, step := range s.config.Steps {
+		request, err := s.buildStepRequest(i, values)
+		if err != nil {
+			message := formatResponse(index, 0, 0, []byte(fmt.Sprintf("step %s: error building request: %v", step.Name, err)))
+			return model.Response{Type: model.ERROR, Message: message, Error: err.Error()}, nil
+		}
+
+		res, err = s.sendRecord(ctx, *request, index)
+		if err != nil {
+			return res, err
+		}
+		if i == len(s.config.Steps)-1 {
+			s.checkResponse(&res, index)
+		}
+		res.Message = labelStep(res, index, step.Name)
+		if res.Type != model.SUCCESS {
+			return res, nil
+		}
+		values[step.Name] = s.stepExtracted(i, res)
+	}
+	return res, nil
+}
+
+// stepExtracted returns the values the extract of the step takes from its response.
+func (s *ProcessService) stepExtracted(step int, res model.Response) map[string]string {
+	conf := s.config.Steps[step].Extract
+	extracted := s.steps[step].extractor.extract(res.Headers, res.Body)
+	values := make(map[string]string, len(extracted))
+	for i, value := range extracted {
+		values[conf[i].Column] = value
+	}
+	return values
+}
+
+func (s *ProcessService) buildStepRequest(i int, values map[string]any) (*http.Request, error) {
+	step, compiled := s.config.Steps[i], s.steps[i]
+	endpoint, err := renderStepPart(compiled.endpoint, step.ApiEndpoint, values)
+	if err != nil {
+		return nil, err
+	}
+	body, err := renderStepPart(compiled.body, step.Body, values)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := stepHeaders(step, s.config.Headers)
+	for name, header := range compiled.headers {
+		if headers[name], err = renderTemplate(header, values); err != nil {
+			return nil, err
+		}
+	}
+
+	method := step.Method
+	if method == "" {
+		method = s.config.Method
+	}
+
+	return createHttpRequest(model.NewCsvRequest(
+		model.WithMethod(method),
+		model.WithHeaders(headers),
+		model.WithBody(body),
+		model.WithRequestUrl(endpoint),
+	))
+}
+
+// renderStepPart renders a part of a step, or returns its text when it is
+// not a template.
+func renderStepPart(t *template.Template, text string, values map[string]any) (string, error) {
+	if t == nil {
+		return text, nil
+	}
+	return renderTemplate(t, values)
+}
+
+// labelStep names the step in the message of its response, after the
+// index, status and attempts.
+func labelStep(res model.Response, index int, step string) string {
+	prefix := formatResponse(index, res.Status, res.Attempts, nil)
+	body, ok := strings.CutPrefix(res.Message, prefix)
+	if !ok {
+		return res.Message
+	}
+	return prefix + "step " + step + ": " + body
+}
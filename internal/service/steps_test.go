@@ -0,0 +1,309 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProcessService_ProcessAll_Steps(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	mockService := &MockHttpService{
+		callFunc: func(record http.Request) ([]byte, int, error) {
+			body, _ := io.ReadAll(record.Body)
+			mu.Lock()
+			sent = append(sent, record.Method+" "+record.URL.String()+" "+record.Header.Get("If-Match")+" "+string(body))
+			mu.Unlock()
+			if record.Method == "GET" {
+				return []byte(`{"status":"draft"}`), 200, nil
+			}
+			if strings.HasSuffix(record.URL.Path, "/locked") {
+				return []byte("conflict"), 409, nil
+			}
+			return []byte(`{"status":"published"}`), 200, nil
+		},
+		headerFunc: func(record http.Request) http.Header {
+			return http.Header{"Etag": {`"v3"`}}
+		},
+	}
+	config := model.Config{
+		Method:  "GET",
+		Headers: map[string]string{"Authorization": "Bearer token"},
+		Steps: []model.Step{
+			{
+				Name:        "get",
+				ApiEndpoint: "https://api.example.com/items/{{.id}}",
+				Extract: []model.Extraction{
+					{Column: "etag", Header: "ETag"},
+					{Column: "status", JSONPath: "$.status"},
+				},
+			},
+			{
+				Name:        "publish",
+				ApiEndpoint: "https://api.example.com/items/{{.id}}",
+				Method:      "PUT",
+				Headers:     map[string]string{"If-Match": "{{.get.etag}}"},
+				Body:        `{"from":{{json .get.status}},"to":"published"}`,
+			},
+		},
+	}
+	service := &ProcessService{config: config, httpService: mockService}
+
+	records := func(yield func(model.Record, error) bool) {
+		if !yield(model.Record{Index: 0, Row: []string{"1"}, Values: map[string]string{"id": "1"}}, nil) {
+			return
+		}
+		yield(model.Record{Index: 1, Row: []string{"locked"}, Values: map[string]string{"id": "locked"}}, nil)
+	}
+
+	writer := &memoryWriter{}
+	if err := service.ProcessAll(context.Background(), records, writer); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedSent := []string{
+		`GET https://api.example.com/items/1  `,
+		`PUT https://api.example.com/items/1 "v3" {"from":"draft","to":"published"}`,
+		`GET https://api.example.com/items/locked  `,
+		`PUT https://api.example.com/items/locked "v3" {"from":"draft","to":"published"}`,
+	}
+	if len(sent) != len(expectedSent) {
+		t.Fatalf("Expected %d requests, got %v", len(expectedSent), sent)
+	}
+	for i := range expectedSent {
+		if sent[i] != expectedSent[i] {
+			t.Errorf("Request %d: expected %q, got %q", i, expectedSent[i], sent[i])
+		}
+	}
+
+	first := writer.responses[0]
+	if first.Type != model.SUCCESS || first.Message != `0-200 [attempts=1] - step publish: {"status":"published"}` {
+		t.Errorf("Unexpected response for the chained row: %v %q", first.Type, first.Message)
+	}
+	if first.RequestHeaders.Get("Authorization") != "Bearer token" {
+		t.Error("Expected the config headers to be sent by the steps")
+	}
+	if first.Row[0] != "1" {
+		t.Errorf("Expected the original row, got %v", first.Row)
+	}
+
+	second := writer.responses[1]
+	if second.Type != model.ERROR || second.Message != "1-409 [attempts=1] - step publish: conflict" {
+		t.Errorf("Unexpected response for the failed step: %v %q", second.Type, second.Message)
+	}
+}
+
+func TestProcessService_processSteps_StopsAtFailedStep(t *testing.T) {
+	calls := 0
+	mockService := &MockHttpService{
+		callFunc: func(record http.Request) ([]byte, int, error) {
+			calls++
+			return []byte("not found"), 404, nil
+		},
+	}
+	config := model.Config{
+		Method: "GET",
+		Steps: []model.Step{
+			{Name: "lookup", ApiEndpoint: "https://api.example.com/users/{{.user}}"},
+			{Name: "update", ApiEndpoint: "https://api.example.com/users/{{.user}}", Method: "PATCH"},
+		},
+	}
+	service := &ProcessService{config: config, httpService: mockService}
+
+	res, err := service.processSteps(context.Background(), map[string]string{"user": "7"}, 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected the chain to stop after the failed step, got %d calls", calls)
+	}
+	if res.Type != model.ERROR || res.Message != "3-404 [attempts=1] - step lookup: not found" {
+		t.Errorf("Unexpected response: %v %q", res.Type, res.Message)
+	}
+}
+
+func TestProcessService_processSteps_AssertionsOnLastStep(t *testing.T) {
+	var sent []string
+	mockService := &MockHttpService{
+		callFunc: func(record http.Request) ([]byte, int, error) {
+			sent = append(sent, record.Method)
+			if record.Method == "GET" {
+				return []byte(`{"id":"42"}`), 200, nil
+			}
+			return []byte(`{"status":"` + record.URL.Query().Get("status") + `"}`), 200, nil
+		},
+	}
+	config := model.Config{
+		Method:     "GET",
+		Assertions: []model.Assertion{{JSONPath: "$.status", Equals: json.RawMessage(`"DONE"`)}},
+		Extract:    []model.Extraction{{Column: "status", JSONPath: "$.status"}},
+		Steps: []model.Step{
+			{
+				Name:        "get",
+				ApiEndpoint: "https://api.example.com/jobs/{{.job}}",
+				Extract:     []model.Extraction{{Column: "id", JSONPath: "$.id"}},
+			},
+			{Name: "put", ApiEndpoint: "https://api.example.com/jobs/{{.get.id}}?status={{.status}}", Method: "PUT"},
+		},
+	}
+	service := &ProcessService{config: config, httpService: mockService}
+
+	res, err := service.processSteps(context.Background(), map[string]string{"job": "1", "status": "DONE"}, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(sent, " ") != "GET PUT" {
+		t.Errorf("Expected both steps to be sent, got %v", sent)
+	}
+	if res.Type != model.SUCCESS || len(res.Extracted) != 1 || res.Extracted[0] != "DONE" {
+		t.Errorf("Expected the last step to pass the assertions, got %v %q %v", res.Type, res.Message, res.Extracted)
+	}
+
+	sent = nil
+	res, err = service.processSteps(context.Background(), map[string]string{"job": "1", "status": "FAILED"}, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `1-200 [attempts=1] - step put: assertion "$.status" failed: got "FAILED", expected "DONE" - {"status":"FAILED"}`
+	if res.Type != model.ERROR || res.Message != expected {
+		t.Errorf("Expected the last step to fail the assertions, got %v %q", res.Type, res.Message)
+	}
+}
+
+func TestProcessService_processSteps_BigNumericID(t *testing.T) {
+	var sent []string
+	mockService := &MockHttpService{
+		callFunc: func(record http.Request) ([]byte, int, error) {
+			sent = append(sent, record.Method+" "+record.URL.Path)
+			return []byte(`{"id":9007199254740993}`), 200, nil
+		},
+	}
+	config := model.Config{
+		Method: "GET",
+		Steps: []model.Step{
+			{
+				Name:        "get",
+				ApiEndpoint: "https://api.example.com/orders/latest",
+				Extract:     []model.Extraction{{Column: "rid", JSONPath: "$.id"}},
+			},
+			{Name: "put", ApiEndpoint: "https://api.example.com/orders/{{.get.rid}}", Method: "PUT"},
+		},
+	}
+	service := &ProcessService{config: config, httpService: mockService}
+
+	if _, err := service.processSteps(context.Background(), nil, 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sent) != 2 || sent[1] != "PUT /orders/9007199254740993" {
+		t.Errorf("Expected the exact ID in the chained request, got %v", sent)
+	}
+}
+
+func TestProcessService_processSteps_MissingValue(t *testing.T) {
+	calls := 0
+	mockService := &MockHttpService{
+		callFunc: func(record http.Request) ([]byte, int, error) {
+			calls++
+			return []byte("{}"), 200, nil
+		},
+	}
+	config := model.Config{
+		Method: "POST",
+		Steps: []model.Step{
+			{Name: "create", ApiEndpoint: "https://api.example.com/orders"},
+			{Name: "pay", ApiEndpoint: "https://api.example.com/orders/{{.create.id}}/pay"},
+		},
+	}
+	service := &ProcessService{config: config, httpService: mockService}
+
+	res, err := service.processSteps(context.Background(), nil, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected only the first step to be sent, got %d calls", calls)
+	}
+	if res.Type != model.ERROR || !strings.Contains(res.Error, `error rendering api_endpoint`) || !strings.Contains(res.Error, `no entry for key "id"`) {
+		t.Errorf("Expected the missing value error, got %v %q", res.Type, res.Error)
+	}
+	if !strings.HasPrefix(res.Message, "0-0 [attempts=0] - step pay: error building request") {
+		t.Errorf("Unexpected message %q", res.Message)
+	}
+}
+
+func TestCompileSteps_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []model.Step
+		headers map[string]string
+	}{
+		{name: "no name", steps: []model.Step{{ApiEndpoint: "https://api.example.com"}}},
+		{name: "duplicate name", steps: []model.Step{
+			{Name: "a", ApiEndpoint: "https://api.example.com"},
+			{Name: "a", ApiEndpoint: "https://api.example.com"},
+		}},
+		{name: "no endpoint", steps: []model.Step{{Name: "a"}}},
+		{name: "bad extract", steps: []model.Step{
+			{Name: "a", ApiEndpoint: "https://api.example.com", Extract: []model.Extraction{{Column: "id"}}},
+		}},
+		{name: "bad body template", steps: []model.Step{
+			{Name: "a", ApiEndpoint: "https://api.example.com", Body: `{"id":{{json .id}`},
+		}},
+		{name: "bad config header template", steps: []model.Step{
+			{Name: "a", ApiEndpoint: "https://api.example.com"},
+		}, headers: map[string]string{"Authorization": "Bearer {{.token"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := compileSteps(tt.steps, tt.headers); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestProcessService_buildStepRequest_Templates(t *testing.T) {
+	config := model.Config{
+		Method:  "POST",
+		Headers: map[string]string{"Authorization": "Bearer {{.token}}", "Accept": "application/json"},
+		Steps: []model.Step{{
+			Name:        "note",
+			ApiEndpoint: "https://api.example.com/items/{{.get.id}}/notes?author={{queryEscape .author}}",
+			Headers:     map[string]string{"Accept": "text/plain"},
+			Body:        `{"text":{{json .text}},"ref":"{{jsonEscape .get.ref}}"}`,
+		}},
+	}
+	service := &ProcessService{config: config}
+	if err := service.compileResponseHandling(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	values := map[string]any{
+		"token":  "t0k",
+		"author": "Ann & Bob",
+		"text":   `say "hi" \ bye`,
+		"get":    map[string]string{"id": "42", "ref": `a"b`},
+	}
+	request, err := service.buildStepRequest(0, values)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if request.URL.String() != "https://api.example.com/items/42/notes?author=Ann+%26+Bob" {
+		t.Errorf("Unexpected URL %s", request.URL)
+	}
+	if request.Header.Get("Authorization") != "Bearer t0k" || request.Header.Get("Accept") != "text/plain" {
+		t.Errorf("Unexpected headers %v", request.Header)
+	}
+	body, _ := io.ReadAll(request.Body)
+	var decoded map[string]string
+	if err := json.Unmarshal(body, &decoded); err != nil || decoded["text"] != `say "hi" \ bye` || decoded["ref"] != `a"b` {
+		t.Errorf("Expected a valid JSON body with the values escaped, got %s (err %v)", body, err)
+	}
+}
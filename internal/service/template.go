@@ -0,0 +1,138 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the helpers available to the request templates.
+var templateFuncs = template.FuncMap{
+	"json":        templateJSON,
+	"jsonEscape":  templateJSONEscape,
+	"base64":      templateBase64,
+	"uuid":        templateUUID,
+	"now":         templateNow,
+	"upper":       strings.ToUpper,
+	"lower":       strings.ToLower,
+	"default":     templateDefault,
+	"pathEscape":  url.PathEscape,
+	"queryEscape": url.QueryEscape,
+}
+
+// requestTemplate holds the parts of the request written as Go templates.
+// Parts without template actions are nil and used as they are.
+type requestTemplate struct {
+	endpoint *template.Template
+	headers  map[string]*template.Template
+	body     *template.Template
+}
+
+func newRequestTemplate(config model.Config) (*requestTemplate, error) {
+	var err error
+	t := &requestTemplate{headers: make(map[string]*template.Template)}
+	if t.endpoint, err = parseTemplate("api_endpoint", config.ApiEndpoint); err != nil {
+		return nil, err
+	}
+	for name, value := range config.Headers {
+		header, err := parseTemplate("header "+name, value)
+		if err != nil {
+			return nil, err
+		}
+		if header != nil {
+			t.headers[name] = header
+		}
+	}
+	if config.BodyTemplate != "" {
+		if t.body, err = newTemplate("body_template", config.BodyTemplate); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// parseTemplate compiles text when it contains template actions.
+func parseTemplate(name, text string) (*template.Template, error) {
+	if !strings.Contains(text, "{{") {
+		return nil, nil
+	}
+	return newTemplate(name, text)
+}
+
+func newTemplate(name, text string) (*template.Template, error) {
+	// a misspelled column fails the row instead of rendering "<no value>"
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// active tells whether any part of the request is a template.
+func (t *requestTemplate) active() bool {
+	return t.endpoint != nil || t.body != nil || len(t.headers) > 0
+}
+
+func renderTemplate(t *template.Template, values any) (string, error) {
+	var rendered strings.Builder
+	if err := t.Execute(&rendered, values); err != nil {
+		return "", fmt.Errorf("error rendering %s: %w", t.Name(), err)
+	}
+	return rendered.String(), nil
+}
+
+// templateJSON encodes a value as JSON, quotes included for strings.
+func templateJSON(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// templateJSONEscape escapes a string to be placed between quotes in a JSON document.
+func templateJSONEscape(value string) (string, error) {
+	encoded, err := templateJSON(value)
+	if err != nil {
+		return "", err
+	}
+	return encoded[1 : len(encoded)-1], nil
+}
+
+func templateBase64(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+// templateUUID returns a random (version 4) UUID.
+func templateUUID() (string, error) {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		return "", err
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
+
+// templateNow formats the current time with the layout, RFC 3339 by default.
+func templateNow(layout ...string) string {
+	if len(layout) > 0 {
+		return time.Now().Format(layout[0])
+	}
+	return time.Now().Format(time.RFC3339)
+}
+
+// templateDefault returns value, or fallback when value is empty, so it
+// can end a pipeline: {{.status | default "active"}}.
+func templateDefault(fallback, value string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRequestTemplate_Helpers(t *testing.T) {
+	values := map[string]string{"name": `Ada "the first"`, "city": "", "code": "ab"}
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{name: "json", template: `{"name":{{json .name}}}`, expected: `{"name":"Ada \"the first\""}`},
+		{name: "jsonEscape", template: `{"name":"{{jsonEscape .name}}"}`, expected: `{"name":"Ada \"the first\""}`},
+		{name: "base64", template: `{{base64 "user:pass"}}`, expected: "dXNlcjpwYXNz"},
+		{name: "upper", template: `{{upper .code}}`, expected: "AB"},
+		{name: "lower", template: `{{lower "AB"}}`, expected: "ab"},
+		{name: "default empty", template: `{{.city | default "Paris"}}`, expected: "Paris"},
+		{name: "default set", template: `{{.code | default "xx"}}`, expected: "ab"},
+		{name: "now layout", template: `{{now "2006"}}`, expected: time.Now().Format("2006")},
+		{name: "pathEscape", template: `/users/{{pathEscape .name}}`, expected: "/users/Ada%20%22the%20first%22"},
+		{name: "queryEscape", template: `?q={{queryEscape .name}}`, expected: "?q=Ada+%22the+first%22"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := newTemplate("test", tt.template)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			rendered, err := renderTemplate(tmpl, values)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if rendered != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, rendered)
+			}
+		})
+	}
+}
+
+func TestTemplateUUID(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, err := templateUUID()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, _ := templateUUID()
+	if !uuidPattern.MatchString(first) {
+		t.Errorf("Expected a version 4 UUID, got %q", first)
+	}
+	if first == second {
+		t.Error("Expected a new UUID at every call")
+	}
+}
+
+func TestNewRequestTemplate(t *testing.T) {
+	templates, err := newRequestTemplate(model.Config{
+		ApiEndpoint: "https://api.example.com/{id}",
+		Headers:     map[string]string{"Content-Type": "application/json", "X-Tenant": "{{.tenant}}"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if templates.endpoint != nil || templates.body != nil {
+		t.Error("Parts without template actions must be used as they are")
+	}
+	if len(templates.headers) != 1 || templates.headers["X-Tenant"] == nil {
+		t.Errorf("Expected only the templated header, got %v", templates.headers)
+	}
+
+	if _, err := newRequestTemplate(model.Config{BodyTemplate: "{{.id"}); err == nil {
+		t.Error("Expected an error for an invalid template")
+	}
+
+	tmpl, _ := newTemplate("body_template", "{{.missing}}")
+	if _, err := renderTemplate(tmpl, map[string]string{"id": "1"}); err == nil {
+		t.Error("Expected an error for a column that does not exist")
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// loadTLSConfig builds the TLS configuration of the transport, loading the
+// CA bundles and the client certificate from disk.
+func loadTLSConfig(conf model.TLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         conf.ServerName,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+
+	if conf.MinVersion != "" {
+		version, ok := tlsVersions[conf.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported tls min_version %q, expected one of 1.0, 1.1, 1.2, 1.3", conf.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if conf.CAFile != "" || conf.CADir != "" {
+		pool, err := loadCertPool(conf.CAFile, conf.CADir)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		if conf.CertFile == "" || conf.KeyFile == "" {
+			return nil, errors.New("tls cert_file and key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// loadCertPool adds the certificates of caFile and of every file in caDir
+// to the system pool. Files in caDir without certificates are ignored, but
+// caFile and caDir must each provide at least one.
+func loadCertPool(caFile, caDir string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading tls ca_file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls ca_file %s", caFile)
+		}
+	}
+
+	if caDir != "" {
+		entries, err := os.ReadDir(caDir)
+		if err != nil {
+			return nil, fmt.Errorf("error reading tls ca_dir: %w", err)
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			pem, err := os.ReadFile(filepath.Join(caDir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("error reading tls ca_dir: %w", err)
+			}
+			if pool.AppendCertsFromPEM(pem) {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no certificates found in tls ca_dir %s", caDir)
+		}
+	}
+
+	return pool, nil
+}
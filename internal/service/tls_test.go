@@ -0,0 +1,231 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert generates a self-signed client certificate and writes it
+// with its key to dir as PEM files.
+func writeClientCert(t *testing.T, dir string) (certPath, keyPath string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "batch-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, _ = x509.ParseCertificate(der)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certPath = filepath.Join(dir, "client.crt")
+	keyPath = filepath.Join(dir, "client.key")
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "EC PRIVATE KEY", keyDER)
+	return certPath, keyPath, cert
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func callTLS(t *testing.T, conf model.TLS, url string) error {
+	t.Helper()
+	client, err := NewHttpClient(model.Config{TLS: conf})
+	if err != nil {
+		t.Fatalf("NewHttpClient failed: %v", err)
+	}
+	realService := &HttpServiceReal{client: client}
+	req, _ := http.NewRequest("GET", url, nil)
+	_, _, _, err = realService.call(context.Background(), *req)
+	return err
+}
+
+func TestLoadTLSConfig_Defaults(t *testing.T) {
+	tlsConfig, err := loadTLSConfig(model.TLS{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if tlsConfig.InsecureSkipVerify {
+		t.Error("Expected certificate verification to be enabled by default")
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected TLS 1.2 minimum, got %x", tlsConfig.MinVersion)
+	}
+	if tlsConfig.RootCAs != nil {
+		t.Error("Expected the system roots when no CA is configured")
+	}
+	if len(tlsConfig.Certificates) != 0 {
+		t.Error("Expected no client certificate")
+	}
+}
+
+func TestLoadTLSConfig_Settings(t *testing.T) {
+	tlsConfig, err := loadTLSConfig(model.TLS{
+		ServerName:         "internal.example.com",
+		MinVersion:         "1.3",
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if tlsConfig.ServerName != "internal.example.com" {
+		t.Errorf("Expected server name override, got %q", tlsConfig.ServerName)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3 minimum, got %x", tlsConfig.MinVersion)
+	}
+	if !tlsConfig.InsecureSkipVerify {
+		t.Error("Expected InsecureSkipVerify to be enabled explicitly")
+	}
+}
+
+func TestLoadTLSConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	certPath, _, _ := writeClientCert(t, dir)
+	emptyFile := filepath.Join(dir, "empty.pem")
+	os.WriteFile(emptyFile, []byte("not a certificate"), 0644)
+	emptyDir := t.TempDir()
+
+	tests := []struct {
+		name string
+		conf model.TLS
+	}{
+		{name: "unknown min version", conf: model.TLS{MinVersion: "1.4"}},
+		{name: "cert without key", conf: model.TLS{CertFile: certPath}},
+		{name: "key without cert", conf: model.TLS{KeyFile: certPath}},
+		{name: "missing ca file", conf: model.TLS{CAFile: filepath.Join(dir, "missing.pem")}},
+		{name: "ca file without certificates", conf: model.TLS{CAFile: emptyFile}},
+		{name: "ca dir without certificates", conf: model.TLS{CADir: emptyDir}},
+		{name: "missing client key", conf: model.TLS{CertFile: certPath, KeyFile: filepath.Join(dir, "missing.key")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadTLSConfig(tt.conf); err == nil {
+				t.Error("Expected an error")
+			}
+			if _, err := NewHttpClient(model.Config{TLS: tt.conf}); err == nil {
+				t.Error("Expected NewHttpClient to fail")
+			}
+		})
+	}
+}
+
+func TestNewHttpClient_CustomCA(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer testServer.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", testServer.Certificate().Raw)
+
+	t.Run("untrusted server is rejected", func(t *testing.T) {
+		err := callTLS(t, model.TLS{}, testServer.URL)
+		if err == nil {
+			t.Fatal("Expected a certificate error")
+		}
+		if kind := errorKind(err); kind != errorKindTLS {
+			t.Errorf("Expected error kind %q, got %q (%v)", errorKindTLS, kind, err)
+		}
+	})
+
+	t.Run("ca file", func(t *testing.T) {
+		if err := callTLS(t, model.TLS{CAFile: caFile}, testServer.URL); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("ca dir", func(t *testing.T) {
+		os.WriteFile(filepath.Join(dir, "README"), []byte("bundles"), 0644)
+		if err := callTLS(t, model.TLS{CADir: dir}, testServer.URL); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("insecure skip verify", func(t *testing.T) {
+		if err := callTLS(t, model.TLS{InsecureSkipVerify: true}, testServer.URL); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestNewHttpClient_ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCert := writeClientCert(t, dir)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	testServer.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	testServer.StartTLS()
+	defer testServer.Close()
+
+	if err := callTLS(t, model.TLS{InsecureSkipVerify: true}, testServer.URL); err == nil {
+		t.Error("Expected the server to reject a request without client certificate")
+	}
+
+	client, err := NewHttpClient(model.Config{TLS: model.TLS{
+		CertFile:           certPath,
+		KeyFile:            keyPath,
+		InsecureSkipVerify: true,
+	}})
+	if err != nil {
+		t.Fatalf("NewHttpClient failed: %v", err)
+	}
+	realService := &HttpServiceReal{client: client}
+
+	req, _ := http.NewRequest("GET", testServer.URL, nil)
+	body, status, _, err := realService.call(context.Background(), *req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status != http.StatusOK || string(body) != "batch-client" {
+		t.Errorf("Expected the client certificate to be presented, got %d %q", status, body)
+	}
+}
@@ -0,0 +1,384 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"batchRequestsRecover/internal/util"
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Output file suffixes, appended to the input file path.
+// FailedSuffix is followed by the extension of the input file.
+const (
+	RespSuffix    = ".resp"
+	ErrSuffix     = ".err"
+	SkippedSuffix = ".skipped"
+	FailedSuffix  = ".failed"
+	OutSuffix     = ".out.tsv"
+)
+
+const defaultFailedExt = ".tsv"
+
+// ResponseWriter receives every response as soon as it is produced, in row order.
+type ResponseWriter interface {
+	Write(index int, response model.Response) error
+}
+
+// FileResponseWriter streams responses to the .resp, .err and .skipped files, and to
+// the result log when enabled. The raw input rows of the failed records go
+// to the failed rows file, which can be used as the input of another run.
+// With extract columns, the rows of the successful records followed by the
+// extracted values go to the tab separated out file.
+// Lines are buffered and flushed every FlushEvery responses; only once they
+// are flushed (and synced when Fsync is set) are the rows recorded in the
+// checkpoint, so the checkpoint never lists a row whose output was lost.
+type FileResponseWriter struct {
+	mu         sync.Mutex
+	files      map[model.ResponseType]*outputFile
+	results    *outputFile
+	failed     *outputFile
+	failedBOM  bool
+	noFailed   bool
+	out        *outputFile
+	outCSV     *csv.Writer
+	columns    []string
+	redact     []string
+	checkpoint *Checkpoint
+	pending    []checkpointEntry
+	flushEvery int
+	fsync      bool
+}
+
+type outputFile struct {
+	file   *os.File
+	buffer *bufio.Writer
+}
+
+type checkpointEntry struct {
+	index    int
+	response model.Response
+}
+
+// FileResponseWriterOption configures optional outputs of the writer.
+type FileResponseWriterOption func(*FileResponseWriter)
+
+// WithFailedBOM starts a new failed rows file with a UTF-8 byte order mark,
+// so a follow-up run reads it as UTF-8 whatever the input_encoding.
+func WithFailedBOM() FileResponseWriterOption {
+	return func(w *FileResponseWriter) {
+		w.failedBOM = true
+	}
+}
+
+// WithoutFailedRows disables the failed rows file, for inputs whose records
+// have no raw row to write back.
+func WithoutFailedRows() FileResponseWriterOption {
+	return func(w *FileResponseWriter) {
+		w.noFailed = true
+	}
+}
+
+// WithExtractColumns enables the out file, with the given extract columns.
+func WithExtractColumns(columns []string) FileResponseWriterOption {
+	return func(w *FileResponseWriter) {
+		w.columns = columns
+	}
+}
+
+// NewFileResponseWriter opens the output files next to the input file,
+// including the result log when output.JSONL is set. With appendMode the files of a previous run are kept and extended,
+// otherwise they are truncated.
+func NewFileResponseWriter(inputFilePath string, output model.Output, checkpoint *Checkpoint, appendMode bool, opts ...FileResponseWriterOption) (*FileResponseWriter, error) {
+	writer := &FileResponseWriter{
+		files:      make(map[model.ResponseType]*outputFile),
+		checkpoint: checkpoint,
+		flushEvery: max(output.FlushEvery, 1),
+		fsync:      output.Fsync,
+	}
+	for _, opt := range opts {
+		opt(writer)
+	}
+
+	suffixes := map[model.ResponseType]string{
+		model.SUCCESS: RespSuffix,
+		model.ERROR:   ErrSuffix,
+		model.SKIPPED: SkippedSuffix,
+	}
+	for responseType, suffix := range suffixes {
+		file, err := openOutputFile(inputFilePath+suffix, appendMode)
+		if err != nil {
+			writer.closeFiles()
+			return nil, err
+		}
+		writer.files[responseType] = file
+	}
+
+	if !writer.noFailed {
+		failed, err := openOutputFile(FailedPath(inputFilePath), appendMode)
+		if err != nil {
+			writer.closeFiles()
+			return nil, err
+		}
+		writer.failed = failed
+	}
+
+	if len(writer.columns) > 0 {
+		out, err := openOutputFile(inputFilePath+OutSuffix, appendMode)
+		if err != nil {
+			writer.closeFiles()
+			return nil, err
+		}
+		writer.out = out
+		writer.outCSV = csv.NewWriter(out.buffer)
+		writer.outCSV.Comma = '\t'
+	}
+
+	if output.JSONL {
+		file, err := openOutputFile(inputFilePath+ResultsSuffix, appendMode)
+		if err != nil {
+			writer.closeFiles()
+			return nil, err
+		}
+		writer.results = file
+		writer.redact = output.RedactHeaders
+		if writer.redact == nil {
+			writer.redact = defaultRedactHeaders
+		}
+	}
+	return writer, nil
+}
+
+// FailedPath is the path of the failed rows file of an input file, which
+// keeps the input extension so it can be fed to another run as it is.
+// The rows of workbooks are written as delimited text, in a .tsv file.
+func FailedPath(inputFilePath string) string {
+	ext := filepath.Ext(inputFilePath)
+	if ext == "" || strings.EqualFold(ext, ".xlsx") {
+		ext = defaultFailedExt
+	}
+	return inputFilePath + FailedSuffix + ext
+}
+
+func openOutputFile(path string, appendMode bool) (*outputFile, error) {
+	flags := os.O_CREATE | os.O_RDWR | os.O_TRUNC
+	if appendMode {
+		flags = os.O_CREATE | os.O_RDWR | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
+
+	output := &outputFile{file: file, buffer: bufio.NewWriter(file)}
+	if appendMode {
+		if err := output.terminateLastLine(); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error preparing %s: %w", path, err)
+		}
+	}
+	return output, nil
+}
+
+// terminateLastLine makes sure appended lines do not merge with
+// a last line written without a trailing newline.
+func (o *outputFile) terminateLastLine() error {
+	info, err := o.file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := o.file.ReadAt(last, info.Size()-1); err != nil && err != io.EOF {
+		return err
+	}
+	if last[0] != '\n' {
+		_, err = o.buffer.WriteString("\n")
+	}
+	return err
+}
+
+// WriteHeader starts the failed rows file with the header row of the
+// input, and the out file with the same header followed by the extract
+// columns. Files a previous run already wrote to are left as they are.
+func (w *FileResponseWriter) WriteHeader(header model.Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed != nil && (len(header.Raw) > 0 || w.failedBOM) {
+		empty, err := w.failed.isEmpty()
+		if err != nil {
+			return fmt.Errorf("error writing header: %w", err)
+		}
+		if empty && w.failedBOM {
+			if _, err := w.failed.buffer.WriteString(util.UTF8BOM); err != nil {
+				return fmt.Errorf("error writing header: %w", err)
+			}
+		}
+		if empty && len(header.Raw) > 0 {
+			if err := w.failed.writeLine(header.Raw); err != nil {
+				return fmt.Errorf("error writing header: %w", err)
+			}
+		}
+	}
+
+	if w.out != nil && len(header.Row) > 0 {
+		empty, err := w.out.isEmpty()
+		if err != nil {
+			return fmt.Errorf("error writing header: %w", err)
+		}
+		if empty {
+			if err := w.writeOut(slices.Concat(header.Row, w.columns)); err != nil {
+				return fmt.Errorf("error writing header: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Write appends the response message to the file matching its type,
+// the full response to the result log and, when the record failed, its
+// raw row to the failed rows file.
+func (w *FileResponseWriter) Write(index int, response model.Response) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	output, ok := w.files[response.Type]
+	if !ok {
+		output = w.files[model.ERROR]
+	}
+	if _, err := output.buffer.WriteString(response.Message + "\n"); err != nil {
+		return fmt.Errorf("error writing response: %w", err)
+	}
+	if response.Type == model.ERROR && len(response.Raw) > 0 && w.failed != nil {
+		if err := w.failed.writeLine(response.Raw); err != nil {
+			return fmt.Errorf("error writing failed row: %w", err)
+		}
+	}
+	if response.Type == model.SUCCESS && w.out != nil {
+		// values missing from the response keep the columns aligned
+		values := make([]string, len(w.columns))
+		copy(values, response.Extracted)
+		if err := w.writeOut(slices.Concat(response.Row, values)); err != nil {
+			return fmt.Errorf("error writing out row: %w", err)
+		}
+	}
+	if w.results != nil {
+		line, err := encodeResultLine(index, response, w.redact)
+		if err != nil {
+			return fmt.Errorf("error encoding result: %w", err)
+		}
+		if _, err := w.results.buffer.Write(line); err != nil {
+			return fmt.Errorf("error writing result: %w", err)
+		}
+	}
+
+	w.pending = append(w.pending, checkpointEntry{index: index, response: response})
+	if len(w.pending) >= w.flushEvery {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *FileResponseWriter) writeOut(fields []string) error {
+	if err := w.outCSV.Write(fields); err != nil {
+		return err
+	}
+	w.outCSV.Flush()
+	return w.outCSV.Error()
+}
+
+// isEmpty reports whether nothing was written to the file yet.
+func (o *outputFile) isEmpty() (bool, error) {
+	info, err := o.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	return info.Size() == 0 && o.buffer.Buffered() == 0, nil
+}
+
+// writeLine writes raw as it is, terminating it when it has no line terminator.
+func (o *outputFile) writeLine(raw []byte) error {
+	if _, err := o.buffer.Write(raw); err != nil {
+		return err
+	}
+	if raw[len(raw)-1] != '\n' {
+		return o.buffer.WriteByte('\n')
+	}
+	return nil
+}
+
+// Flush writes the buffered responses to disk and records them in the checkpoint.
+func (w *FileResponseWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+func (w *FileResponseWriter) flush() error {
+	for _, output := range w.outputs() {
+		if err := output.buffer.Flush(); err != nil {
+			return fmt.Errorf("error flushing %s: %w", output.file.Name(), err)
+		}
+		if w.fsync {
+			if err := output.file.Sync(); err != nil {
+				return fmt.Errorf("error syncing %s: %w", output.file.Name(), err)
+			}
+		}
+	}
+
+	for _, entry := range w.pending {
+		if err := w.checkpoint.Record(entry.index, entry.response); err != nil {
+			return err
+		}
+	}
+	w.pending = w.pending[:0]
+
+	if w.fsync {
+		return w.checkpoint.Sync()
+	}
+	return nil
+}
+
+// Close flushes what is left and closes the output files.
+func (w *FileResponseWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.flush()
+	if closeErr := w.closeFiles(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// outputs lists every open output file.
+func (w *FileResponseWriter) outputs() []*outputFile {
+	outputs := slices.Collect(maps.Values(w.files))
+	if w.failed != nil {
+		outputs = append(outputs, w.failed)
+	}
+	if w.out != nil {
+		outputs = append(outputs, w.out)
+	}
+	if w.results != nil {
+		outputs = append(outputs, w.results)
+	}
+	return outputs
+}
+
+func (w *FileResponseWriter) closeFiles() error {
+	var err error
+	for _, output := range w.outputs() {
+		if closeErr := output.file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"batchRequestsRecover/internal/model"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileResponseWriter_StreamsByType(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.tsv")
+	checkpoint, err := OpenCheckpoint(input+CheckpointSuffix, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer checkpoint.Close()
+
+	writer, err := NewFileResponseWriter(input, model.Output{}, checkpoint, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := writer.Write(0, model.Response{Type: model.SUCCESS, Message: "0-200 - ok", Status: 200}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := writer.Write(1, model.Response{Type: model.ERROR, Message: "1-500 - ko", Status: 500}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// flush_every defaults to 1, so the lines are on disk before Close
+	resp, _ := os.ReadFile(input + RespSuffix)
+	if string(resp) != "0-200 - ok\n" {
+		t.Errorf("Unexpected .resp content %q", resp)
+	}
+	errContent, _ := os.ReadFile(input + ErrSuffix)
+	if string(errContent) != "1-500 - ko\n" {
+		t.Errorf("Unexpected .err content %q", errContent)
+	}
+	if err := writer.Write(2, model.Response{Type: model.SKIPPED, Message: "2-404 - gone", Status: 404}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	skipped, _ := os.ReadFile(input + SkippedSuffix)
+	if string(skipped) != "2-404 - gone\n" {
+		t.Errorf("Unexpected .skipped content %q", skipped)
+	}
+	if checkpoint.Completed() != 3 {
+		t.Error("Flushed rows should be recorded in the checkpoint")
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(input + ResultsSuffix); !os.IsNotExist(err) {
+		t.Error("The result log should only be written when enabled")
+	}
+}
+
+func TestFileResponseWriter_ResultLog(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.tsv")
+
+	writer, err := NewFileResponseWriter(input, model.Output{JSONL: true}, nil, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err = writer.Write(0, model.Response{
+		Type:     model.SUCCESS,
+		Message:  "0-200 - ignored",
+		Status:   200,
+		Attempts: 2,
+		Row:      []string{"42", "{\"a\":1}"},
+		Method:   "POST",
+		URL:      "https://api.example.com/users/42",
+		RequestHeaders: http.Header{
+			"Authorization": {"Bearer secret"},
+			"Content-Type":  {"application/json"},
+		},
+		Headers: http.Header{"Set-Cookie": {"session=abc"}, "X-Request-Id": {"r1"}},
+		Body:    []byte("line one\nline two - with separator"),
+		Latency: 1500 * time.Microsecond,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = writer.Write(1, model.Response{Type: model.ERROR, Attempts: 3, Method: "GET", Error: "connection refused"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(input + ResultsSuffix)
+	if err != nil {
+		t.Fatalf("Failed to read result log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per response, got %d: %q", len(lines), content)
+	}
+
+	var first resultLine
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Invalid JSON line: %v", err)
+	}
+	if first.Index != 0 || first.Result != "SUCCESS" || first.Status != 200 || first.Attempts != 2 {
+		t.Errorf("Unexpected result fields: %+v", first)
+	}
+	if first.Method != "POST" || first.URL != "https://api.example.com/users/42" {
+		t.Errorf("Unexpected request fields: %+v", first)
+	}
+	if len(first.Row) != 2 || first.Row[1] != "{\"a\":1}" {
+		t.Errorf("Expected the original row, got %v", first.Row)
+	}
+	if first.Body != "line one\nline two - with separator" {
+		t.Errorf("Body should survive newlines and separators, got %q", first.Body)
+	}
+	if first.LatencyMillis != 1.5 {
+		t.Errorf("Expected latency 1.5ms, got %v", first.LatencyMillis)
+	}
+	if first.RequestHeaders["Authorization"][0] != redactedValue {
+		t.Errorf("Authorization should be redacted, got %v", first.RequestHeaders["Authorization"])
+	}
+	if first.RequestHeaders["Content-Type"][0] != "application/json" {
+		t.Errorf("Content-Type should be kept, got %v", first.RequestHeaders["Content-Type"])
+	}
+	if first.ResponseHeaders["Set-Cookie"][0] != redactedValue || first.ResponseHeaders["X-Request-Id"][0] != "r1" {
+		t.Errorf("Unexpected response headers %v", first.ResponseHeaders)
+	}
+	if first.Error != "" {
+		t.Errorf("Expected no error, got %q", first.Error)
+	}
+
+	var second resultLine
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("Invalid JSON line: %v", err)
+	}
+	if second.Result != "ERROR" || second.Error != "connection refused" || second.Status != 0 {
+		t.Errorf("Unexpected error line: %+v", second)
+	}
+}
+
+func TestFileResponseWriter_FailedRows(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.csv")
+	header := model.Record{Row: []string{"id", "body"}, Raw: []byte("id,body\r\n")}
+
+	run := func(appendMode bool, responses map[int]model.Response) {
+		writer, err := NewFileResponseWriter(input, model.Output{}, nil, appendMode)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for index := range len(responses) {
+			if err := writer.Write(index, responses[index]); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	run(false, map[int]model.Response{
+		0: {Type: model.SUCCESS, Raw: []byte("1,ok\r\n")},
+		1: {Type: model.ERROR, Raw: []byte("2,\"a,\"\"b\"\"\"\r\n")},
+		2: {Type: model.ERROR, Raw: []byte("3,last")},
+	})
+
+	path := FailedPath(input)
+	if path != input+".failed.csv" {
+		t.Errorf("Unexpected failed rows path %q", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read failed rows: %v", err)
+	}
+	expected := "id,body\r\n2,\"a,\"\"b\"\"\"\r\n3,last\n"
+	if string(content) != expected {
+		t.Errorf("Expected failed rows %q, got %q", expected, content)
+	}
+
+	// A resumed run appends without repeating the header
+	run(true, map[int]model.Response{0: {Type: model.ERROR, Raw: []byte("4,again\r\n")}})
+	content, _ = os.ReadFile(path)
+	if string(content) != expected+"4,again\r\n" {
+		t.Errorf("Unexpected failed rows after resume %q", content)
+	}
+}
+
+func TestFileResponseWriter_OutFile(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "orders.csv")
+	header := model.Record{Row: []string{"customer", "payload"}, Raw: []byte("customer,payload\n")}
+
+	run := func(appendMode bool, responses []model.Response) {
+		writer, err := NewFileResponseWriter(input, model.Output{}, nil, appendMode, WithExtractColumns([]string{"order_id", "etag"}))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for index, response := range responses {
+			if err := writer.Write(index, response); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	run(false, []model.Response{
+		{Type: model.SUCCESS, Row: []string{"c1", "{\"a\":\"x\ty\"}"}, Extracted: []string{"o-1", "e1"}},
+		{Type: model.ERROR, Row: []string{"c2", "{}"}, Raw: []byte("c2,{}\n")},
+		{Type: model.SUCCESS, Row: []string{"c3", "{}"}, Extracted: []string{"o-3"}},
+	})
+	run(true, []model.Response{{Type: model.SUCCESS, Row: []string{"c4", "{}"}, Extracted: []string{"o-4", "e4"}}})
+
+	content, err := os.ReadFile(input + OutSuffix)
+	if err != nil {
+		t.Fatalf("Failed to read out file: %v", err)
+	}
+	expected := "customer\tpayload\torder_id\tetag\n" +
+		"c1\t\"{\"\"a\"\":\"\"x\ty\"\"}\"\to-1\te1\n" +
+		"c3\t{}\to-3\t\n" +
+		"c4\t{}\to-4\te4\n"
+	if string(content) != expected {
+		t.Errorf("Expected out file %q, got %q", expected, content)
+	}
+}
+
+func TestFileResponseWriter_FailedBOM(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.csv")
+	run := func(appendMode bool, raw string) {
+		writer, err := NewFileResponseWriter(input, model.Output{}, nil, appendMode, WithFailedBOM())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := writer.WriteHeader(model.Record{Row: []string{"id"}, Raw: []byte("id\n")}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := writer.Write(0, model.Response{Type: model.ERROR, Raw: []byte(raw)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		writer.Close()
+	}
+
+	run(false, "café\n")
+	run(true, "naïve\n")
+
+	content, _ := os.ReadFile(FailedPath(input))
+	if string(content) != "\uFEFFid\ncafé\nnaïve\n" {
+		t.Errorf("Expected a single BOM before the header, got %q", content)
+	}
+}
+
+func TestFileResponseWriter_WithoutFailedRows(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "session.har")
+	writer, err := NewFileResponseWriter(input, model.Output{}, nil, false, WithoutFailedRows())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := writer.WriteHeader(model.Record{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := writer.Write(0, model.Response{Type: model.ERROR, Message: "GET https://api.example.com/ 500"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(FailedPath(input)); !os.IsNotExist(err) {
+		t.Error("The failed rows file should not be written")
+	}
+	content, _ := os.ReadFile(input + ErrSuffix)
+	if string(content) != "GET https://api.example.com/ 500\n" {
+		t.Errorf("Unexpected err file %q", content)
+	}
+}
+
+func TestFileResponseWriter_NoExtractColumns(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.tsv")
+	writer, err := NewFileResponseWriter(input, model.Output{}, nil, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	writer.Close()
+
+	if _, err := os.Stat(input + OutSuffix); !os.IsNotExist(err) {
+		t.Error("The out file should only be written with extract columns")
+	}
+}
+
+func TestFailedPath(t *testing.T) {
+	if got := FailedPath("data/orders.tsv"); got != "data/orders.tsv.failed.tsv" {
+		t.Errorf("Unexpected path %q", got)
+	}
+	if got := FailedPath("data/orders"); got != "data/orders.failed.tsv" {
+		t.Errorf("Unexpected path %q", got)
+	}
+	if got := FailedPath("data/orders.XLSX"); got != "data/orders.XLSX.failed.tsv" {
+		t.Errorf("Unexpected path %q", got)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	header := http.Header{
+		"X-Api-Key": {"k1", "k2"},
+		"Accept":    {"*/*"},
+	}
+
+	redacted := redactHeaders(header, []string{"x-api-key"})
+
+	if got := redacted["X-Api-Key"]; len(got) != 2 || got[0] != redactedValue || got[1] != redactedValue {
+		t.Errorf("Expected every value to be masked, got %v", got)
+	}
+	if redacted["Accept"][0] != "*/*" {
+		t.Errorf("Accept should not be masked, got %v", redacted["Accept"])
+	}
+	if header.Get("X-Api-Key") != "k1" {
+		t.Error("The original header must not be modified")
+	}
+	if got := redactHeaders(nil, defaultRedactHeaders); got == nil || len(got) != 0 {
+		t.Errorf("Expected an empty map for missing headers, got %v", got)
+	}
+}
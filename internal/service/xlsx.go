@@ -0,0 +1,422 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Built-in number formats of the spreadsheet standard that show dates and times
+var (
+	xlsxDateFormats = map[int]bool{14: true, 15: true, 16: true, 17: true, 22: true}
+	xlsxTimeFormats = map[int]bool{18: true, 19: true, 20: true, 21: true, 22: true, 45: true, 46: true, 47: true}
+)
+
+// Day zero of the date serial numbers, in the default and in the 1904 date system
+var (
+	xlsxEpoch1900 = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	xlsxEpoch1904 = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+var (
+	errXLSXSheetMissing = errors.New("sheet not found")
+	errXLSXPartMissing  = errors.New("part not found")
+)
+
+// xlsxWorkbook is an open .xlsx file with the parts needed to read the
+// cells of its sheets: the shared strings and the date formats of the styles.
+type xlsxWorkbook struct {
+	zip     *zip.ReadCloser
+	sheets  []xlsxSheet
+	strings []string
+	// dates and times tell, per cell style, whether it shows a date or a time
+	dates    []bool
+	times    []bool
+	date1904 bool
+}
+
+type xlsxSheet struct {
+	name string
+	path string
+}
+
+// xlsxRows streams the rows of the sheet selected by the config. Cells
+// are converted to text as a user would see them, and the raw text of
+// every row is the row written with the csv_delimiter. As workbooks do not
+// store trailing empty cells, rows are padded to the width of the first one.
+func (s *ParserService) xlsxRows(filePath string) iter.Seq2[inputRow, error] {
+	return func(yield func(inputRow, error) bool) {
+		workbook, err := openXLSXWorkbook(filePath)
+		if err != nil {
+			yield(inputRow{}, err)
+			return
+		}
+		defer workbook.zip.Close()
+
+		sheet, err := workbook.sheet(s.config.Sheet)
+		if err != nil {
+			yield(inputRow{}, err)
+			return
+		}
+		width := 0
+		for row, err := range workbook.rows(sheet) {
+			if err != nil {
+				yield(inputRow{}, fmt.Errorf("error reading sheet %q: %w", sheet.name, err))
+				return
+			}
+			if width == 0 && !s.isAEmptyRow(row) {
+				width = len(row)
+			}
+			for len(row) < width {
+				row = append(row, "")
+			}
+			if !yield(inputRow{fields: row, raw: s.formatRow(row)}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// formatRow writes a row as a line of a delimited file.
+func (s *ParserService) formatRow(row []string) []byte {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Comma = s.delimiter()
+	writer.Write(row)
+	writer.Flush()
+	return buf.Bytes()
+}
+
+func openXLSXWorkbook(filePath string) (*xlsxWorkbook, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening workbook: %w", err)
+	}
+	workbook := &xlsxWorkbook{zip: archive}
+	if err := workbook.load(); err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("error reading workbook: %w", err)
+	}
+	return workbook, nil
+}
+
+func (w *xlsxWorkbook) load() error {
+	var book struct {
+		Properties struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := w.decodePart("xl/workbook.xml", &book); err != nil {
+		return err
+	}
+	w.date1904 = book.Properties.Date1904 == "1" || book.Properties.Date1904 == "true"
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := w.decodePart("xl/_rels/workbook.xml.rels", &rels); err != nil && !errors.Is(err, errXLSXPartMissing) {
+		return err
+	}
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+	for i, sheet := range book.Sheets {
+		sheetPath, ok := targets[sheet.ID]
+		if !ok {
+			sheetPath = fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)
+		}
+		w.sheets = append(w.sheets, xlsxSheet{name: sheet.Name, path: sheetPath})
+	}
+
+	if err := w.loadSharedStrings(); err != nil {
+		return err
+	}
+	return w.loadStyles()
+}
+
+func (w *xlsxWorkbook) openPart(name string) (io.ReadCloser, error) {
+	for _, file := range w.zip.File {
+		if strings.EqualFold(file.Name, name) {
+			return file.Open()
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", name, errXLSXPartMissing)
+}
+
+func (w *xlsxWorkbook) decodePart(name string, value any) error {
+	part, err := w.openPart(name)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+	if err := xml.NewDecoder(part).Decode(value); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// xlsxText is a text with optional rich text runs, as found in the shared
+// strings and in inline string cells.
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var text strings.Builder
+	for _, run := range t.Runs {
+		text.WriteString(run.Text)
+	}
+	return text.String()
+}
+
+func (w *xlsxWorkbook) loadSharedStrings() error {
+	var shared struct {
+		Items []xlsxText `xml:"si"`
+	}
+	if err := w.decodePart("xl/sharedStrings.xml", &shared); err != nil {
+		if errors.Is(err, errXLSXPartMissing) {
+			return nil
+		}
+		return err
+	}
+	w.strings = make([]string, len(shared.Items))
+	for i, item := range shared.Items {
+		w.strings[i] = item.String()
+	}
+	return nil
+}
+
+func (w *xlsxWorkbook) loadStyles() error {
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := w.decodePart("xl/styles.xml", &styles); err != nil {
+		if errors.Is(err, errXLSXPartMissing) {
+			return nil
+		}
+		return err
+	}
+
+	customDates := make(map[int]bool)
+	customTimes := make(map[int]bool)
+	for _, format := range styles.NumFmts {
+		customDates[format.ID], customTimes[format.ID] = classifyNumberFormat(format.Code)
+	}
+	w.dates = make([]bool, len(styles.CellXfs))
+	w.times = make([]bool, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		w.dates[i] = xlsxDateFormats[xf.NumFmtID] || customDates[xf.NumFmtID]
+		w.times[i] = xlsxTimeFormats[xf.NumFmtID] || customTimes[xf.NumFmtID]
+	}
+	return nil
+}
+
+// classifyNumberFormat tells whether a custom format code shows a date
+// and whether it shows a time, ignoring its quoted text and its [...]
+// sections such as colors.
+func classifyNumberFormat(code string) (date, clock bool) {
+	inQuotes, inBrackets := false, false
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == '\\':
+			i++
+		case c == '[':
+			inBrackets = true
+		case c == ']':
+			inBrackets = false
+		case inBrackets:
+		default:
+			switch c | 0x20 {
+			case 'y', 'd':
+				date = true
+			case 'h', 's':
+				clock = true
+			}
+		}
+	}
+	return date, clock
+}
+
+// sheet returns the sheet selected by name or by 1-based position, the
+// first one when selection is empty.
+func (w *xlsxWorkbook) sheet(selection string) (xlsxSheet, error) {
+	if len(w.sheets) == 0 {
+		return xlsxSheet{}, errXLSXSheetMissing
+	}
+	if selection == "" {
+		return w.sheets[0], nil
+	}
+	for _, sheet := range w.sheets {
+		if sheet.name == selection {
+			return sheet, nil
+		}
+	}
+	if position, err := strconv.Atoi(selection); err == nil && position >= 1 && position <= len(w.sheets) {
+		return w.sheets[position-1], nil
+	}
+	return xlsxSheet{}, fmt.Errorf("%w: %q", errXLSXSheetMissing, selection)
+}
+
+type xlsxCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Style  int      `xml:"s,attr"`
+	Value  string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+// rows streams the rows of a sheet, decoding one cell at a time. Cells
+// missing from the sheet, before the last one of their row, are empty.
+func (w *xlsxWorkbook) rows(sheet xlsxSheet) iter.Seq2[[]string, error] {
+	return func(yield func([]string, error) bool) {
+		part, err := w.openPart(sheet.path)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer part.Close()
+
+		decoder := xml.NewDecoder(part)
+		var row []string
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			switch element := token.(type) {
+			case xml.StartElement:
+				switch element.Name.Local {
+				case "row":
+					row = []string{}
+				case "c":
+					var cell xlsxCell
+					if err := decoder.DecodeElement(&cell, &element); err != nil {
+						yield(nil, err)
+						return
+					}
+					column := len(row)
+					if cell.Ref != "" {
+						if column, err = xlsxColumn(cell.Ref); err != nil {
+							yield(nil, err)
+							return
+						}
+					}
+					for len(row) <= column {
+						row = append(row, "")
+					}
+					row[column] = w.cellValue(cell)
+				}
+			case xml.EndElement:
+				if element.Name.Local == "row" && !yield(row, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// xlsxColumn returns the 0-based column of a cell reference such as "AB12".
+func xlsxColumn(ref string) (int, error) {
+	column := 0
+	for i := 0; i < len(ref); i++ {
+		c := ref[i] | 0x20
+		if c < 'a' || c > 'z' {
+			if i == 0 {
+				break
+			}
+			return column - 1, nil
+		}
+		column = column*26 + int(c-'a'+1)
+	}
+	return 0, fmt.Errorf("invalid cell reference %q", ref)
+}
+
+func (w *xlsxWorkbook) cellValue(cell xlsxCell) string {
+	switch cell.Type {
+	case "s":
+		index, err := strconv.Atoi(cell.Value)
+		if err != nil || index < 0 || index >= len(w.strings) {
+			return ""
+		}
+		return w.strings[index]
+	case "inlineStr":
+		return cell.Inline.String()
+	case "b":
+		return strconv.FormatBool(cell.Value == "1")
+	case "str", "e", "d":
+		return cell.Value
+	}
+
+	number, err := strconv.ParseFloat(cell.Value, 64)
+	if err != nil {
+		return cell.Value
+	}
+	if cell.Style >= 0 && cell.Style < len(w.dates) && (w.dates[cell.Style] || w.times[cell.Style]) {
+		return w.formatDate(number, w.dates[cell.Style], w.times[cell.Style])
+	}
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+// formatDate converts a date serial number to ISO 8601 text: the date,
+// the time of day or both, as the cell format shows them.
+func (w *xlsxWorkbook) formatDate(serial float64, date, clock bool) string {
+	epoch := xlsxEpoch1900
+	if w.date1904 {
+		epoch = xlsxEpoch1904
+	}
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 24 * 60 * 60)
+	value := epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+
+	switch {
+	case date && clock:
+		return value.Format("2006-01-02 15:04:05")
+	case date:
+		return value.Format("2006-01-02")
+	default:
+		return value.Format("15:04:05")
+	}
+}
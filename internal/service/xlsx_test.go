@@ -0,0 +1,196 @@
+package service
+
+import (
+	"archive/zip"
+	"batchRequestsRecover/internal/model"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Summary" sheetId="1" r:id="rId1"/><sheet name="Failed" sheetId="2" r:id="rId2"/></sheets>
+</workbook>`
+
+const testWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/summary.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/failed.xml"/>
+</Relationships>`
+
+const testSharedStrings = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>id</t></si><si><t>amount</t></si><si><t>created</t></si><si><t>active</t></si><si><t>note</t></si>
+<si><r><t>multi</t></r><r><t xml:space="preserve"> run</t></r></si>
+</sst>`
+
+const testStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd\ hh:mm"/></numFmts>
+<cellXfs count="3"><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/></cellXfs>
+</styleSheet>`
+
+const testFailedSheet = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c><c r="E1" t="s"><v>4</v></c></row>
+<row r="2"><c r="A2"><v>1001</v></c><c r="B2"><v>12.5</v></c><c r="C2" s="1"><v>45292</v></c><c r="D2" t="b"><v>1</v></c><c r="E2" t="s"><v>5</v></c></row>
+<row r="4"><c r="A4" t="inlineStr"><is><t>A-7</t></is></c><c r="C4" s="2"><v>45292.5</v></c><c r="D4" t="b"><v>0</v></c></row>
+</sheetData></worksheet>`
+
+const testSummarySheet = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="inlineStr"><is><t>total</t></is></c></row>
+</sheetData></worksheet>`
+
+// writeTestWorkbook writes a workbook with a summary sheet and a sheet of failed transactions.
+func writeTestWorkbook(t *testing.T, path string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create workbook: %v", err)
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	parts := map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testWorkbookRels,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/styles.xml":              testStyles,
+		"xl/worksheets/summary.xml":  testSummarySheet,
+		"xl/worksheets/failed.xml":   testFailedSheet,
+	}
+	for name, content := range parts {
+		part, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		io.WriteString(part, content)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+}
+
+func TestParserService_Records_XLSX(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "failed.xlsx")
+	writeTestWorkbook(t, testFile)
+
+	service := NewParserService(model.Config{
+		ApiEndpoint: "https://api.example.com/payments/{id}",
+		Method:      "POST",
+		PathVars:    []string{"id"},
+		QueryVars:   []string{"created", "active"},
+		HasBody:     true,
+		BodyColumn:  "note",
+		HasHeader:   true,
+		Sheet:       "Failed",
+	})
+
+	var records []model.Record
+	for record, err := range service.Records(testFile) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	expectedURLs := []string{
+		"https://api.example.com/payments/1001?created=2024-01-01&active=true",
+		"https://api.example.com/payments/A-7?created=2024-01-01+12%3A00%3A00&active=false",
+	}
+	for i, record := range records {
+		if record.Request.URL.String() != expectedURLs[i] {
+			t.Errorf("Record %d: expected %s, got %s", i, expectedURLs[i], record.Request.URL)
+		}
+	}
+	body, _ := io.ReadAll(records[0].Request.Body)
+	if string(body) != "multi run" {
+		t.Errorf("Expected the rich text cell, got %q", body)
+	}
+	if string(records[0].Raw) != "1001\t12.5\t2024-01-01\ttrue\tmulti run\n" {
+		t.Errorf("Unexpected raw row %q", records[0].Raw)
+	}
+	if len(records[1].Row) != 5 || records[1].Row[1] != "" || records[1].Row[4] != "" {
+		t.Errorf("Expected missing cells to be empty, got %q", records[1].Row)
+	}
+
+	header, err := service.Header(testFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(header.Raw) != "id\tamount\tcreated\tactive\tnote\n" {
+		t.Errorf("Unexpected header %q", header.Raw)
+	}
+}
+
+func TestXLSXWorkbook_sheet(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "failed.xlsx")
+	writeTestWorkbook(t, testFile)
+	workbook, err := openXLSXWorkbook(testFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer workbook.zip.Close()
+
+	tests := []struct {
+		selection string
+		expected  string
+	}{
+		{selection: "", expected: "Summary"},
+		{selection: "Failed", expected: "Failed"},
+		{selection: "2", expected: "Failed"},
+	}
+	for _, tt := range tests {
+		sheet, err := workbook.sheet(tt.selection)
+		if err != nil || sheet.name != tt.expected {
+			t.Errorf("sheet(%q): expected %s, got %s (err %v)", tt.selection, tt.expected, sheet.name, err)
+		}
+	}
+	for _, selection := range []string{"Missing", "3", "0"} {
+		if _, err := workbook.sheet(selection); err == nil || !strings.Contains(err.Error(), "sheet not found") {
+			t.Errorf("sheet(%q): expected a missing sheet error, got %v", selection, err)
+		}
+	}
+}
+
+func TestClassifyNumberFormat(t *testing.T) {
+	tests := []struct {
+		code  string
+		date  bool
+		clock bool
+	}{
+		{code: "yyyy-mm-dd", date: true},
+		{code: "dd/mm/yyyy hh:mm:ss", date: true, clock: true},
+		{code: "[h]:mm:ss", clock: true},
+		{code: "#,##0.00", date: false},
+		{code: `0.00" days"`, date: false},
+		{code: "[Red]#,##0", date: false},
+	}
+	for _, tt := range tests {
+		date, clock := classifyNumberFormat(tt.code)
+		if date != tt.date || clock != tt.clock {
+			t.Errorf("classifyNumberFormat(%q): expected %v %v, got %v %v", tt.code, tt.date, tt.clock, date, clock)
+		}
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	tests := map[string]int{"A1": 0, "C4": 2, "Z9": 25, "AA10": 26, "ab3": 27}
+	for ref, expected := range tests {
+		if column, err := xlsxColumn(ref); err != nil || column != expected {
+			t.Errorf("xlsxColumn(%q): expected %d, got %d (err %v)", ref, expected, column, err)
+		}
+	}
+	for _, ref := range []string{"", "12", "AB"} {
+		if _, err := xlsxColumn(ref); err == nil {
+			t.Errorf("xlsxColumn(%q): expected an error", ref)
+		}
+	}
+}
@@ -1,21 +1,11 @@
 package util
 
 import (
-	"fmt"
-	"os"
+	"context"
 	"strings"
 	"time"
 )
 
-// RemoveBOM removes UTF-8 BOM from the beginning of the byte slice
-func RemoveBOM(content []byte) []byte {
-	// UTF-8 BOM is: EF BB BF
-	if len(content) >= 3 && content[0] == 0xEF && content[1] == 0xBB && content[2] == 0xBF {
-		return content[3:]
-	}
-	return content
-}
-
 // TrimQuotes removes surrounding single quotes and trims whitespace
 func TrimQuotes(s string) string {
 	// Trim whitespace first
@@ -29,16 +19,18 @@ func TrimQuotes(s string) string {
 	return strings.TrimSpace(s)
 }
 
-func DelayFor(sleep int) {
-	sleepTime := time.Duration(sleep) * time.Millisecond
-	time.Sleep(sleepTime)
-	println("Sleeping for " + sleepTime.String())
-}
-
-func WriteResponses(inputFilePath string, respList []string, suffix string) {
-	respFile := fmt.Sprint(inputFilePath, suffix)
-	err := os.WriteFile(respFile, []byte(strings.Join(respList, "\n")), 0644)
-	if err != nil {
-		fmt.Println("Error writing "+inputFilePath+" file:", err)
+// SleepContext pauses for the given duration, returning early with the
+// context error when ctx is done first
+func SleepContext(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
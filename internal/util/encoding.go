@@ -0,0 +1,157 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// UTF8BOM is the UTF-8 byte order mark.
+const UTF8BOM = "\uFEFF"
+
+// Byte order marks recognised at the start of an input
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// windows1252 maps the bytes 0x80-0x9F of Windows-1252 to their characters.
+// The five bytes the code page leaves undefined keep their Latin-1 value.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// DecodeInput returns a reader converting r from its character encoding to
+// UTF-8. A UTF-8 or UTF-16 byte order mark decides the encoding and is
+// dropped; without one the encoding is the given one: utf-8 (default),
+// utf-16 (little endian), utf-16le, utf-16be, latin1 or windows-1252.
+func DecodeInput(r io.Reader, encoding string) (io.Reader, error) {
+	reader := bufio.NewReader(r)
+	prefix, _ := reader.Peek(3)
+	switch {
+	case bytes.HasPrefix(prefix, bomUTF8):
+		reader.Discard(len(bomUTF8))
+		return reader, nil
+	case bytes.HasPrefix(prefix, bomUTF16LE):
+		reader.Discard(len(bomUTF16LE))
+		return newDecoder(reader, decodeUTF16(false)), nil
+	case bytes.HasPrefix(prefix, bomUTF16BE):
+		reader.Discard(len(bomUTF16BE))
+		return newDecoder(reader, decodeUTF16(true)), nil
+	}
+
+	switch normalizeEncoding(encoding) {
+	case "", "utf-8", "utf8":
+		return reader, nil
+	case "utf-16", "utf16", "utf-16le", "utf16le":
+		return newDecoder(reader, decodeUTF16(false)), nil
+	case "utf-16be", "utf16be":
+		return newDecoder(reader, decodeUTF16(true)), nil
+	case "latin1", "latin-1", "iso-8859-1", "iso8859-1":
+		return newDecoder(reader, decodeLatin1), nil
+	case "windows-1252", "cp1252":
+		return newDecoder(reader, decodeWindows1252), nil
+	}
+	return nil, fmt.Errorf("unknown input encoding %q", encoding)
+}
+
+// IsUTF8 tells whether DecodeInput reads the encoding as it is.
+func IsUTF8(encoding string) bool {
+	switch normalizeEncoding(encoding) {
+	case "", "utf-8", "utf8":
+		return true
+	}
+	return false
+}
+
+func normalizeEncoding(encoding string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(encoding)), "_", "-")
+}
+
+// decoder is a reader that decodes one character at a time from src and
+// returns it encoded as UTF-8.
+type decoder struct {
+	src     *bufio.Reader
+	decode  func(*bufio.Reader) (rune, error)
+	pending []byte
+}
+
+func newDecoder(src *bufio.Reader, decode func(*bufio.Reader) (rune, error)) io.Reader {
+	return &decoder{src: src, decode: decode}
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(d.pending) > 0 {
+			copied := copy(p[n:], d.pending)
+			d.pending = d.pending[copied:]
+			n += copied
+			continue
+		}
+		char, err := d.decode(d.src)
+		if err != nil {
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+		d.pending = utf8.AppendRune(d.pending[:0], char)
+	}
+	return n, nil
+}
+
+func decodeLatin1(src *bufio.Reader) (rune, error) {
+	b, err := src.ReadByte()
+	return rune(b), err
+}
+
+func decodeWindows1252(src *bufio.Reader) (rune, error) {
+	b, err := src.ReadByte()
+	if err == nil && b >= 0x80 && b <= 0x9F {
+		return windows1252[b-0x80], nil
+	}
+	return rune(b), err
+}
+
+// decodeUTF16 decodes code units in the given byte order, joining
+// surrogate pairs. Unpaired surrogates and a truncated code unit at the end
+// of the input decode as U+FFFD.
+func decodeUTF16(bigEndian bool) func(*bufio.Reader) (rune, error) {
+	unit := func(b []byte) rune {
+		if bigEndian {
+			return rune(b[0])<<8 | rune(b[1])
+		}
+		return rune(b[1])<<8 | rune(b[0])
+	}
+
+	return func(src *bufio.Reader) (rune, error) {
+		var b [2]byte
+		if _, err := io.ReadFull(src, b[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return utf8.RuneError, nil
+			}
+			return 0, err
+		}
+		first := unit(b[:])
+		if !utf16.IsSurrogate(first) {
+			return first, nil
+		}
+
+		next, err := src.Peek(2)
+		if err != nil {
+			return utf8.RuneError, nil
+		}
+		char := utf16.DecodeRune(first, unit(next))
+		if char != utf8.RuneError {
+			src.Discard(2)
+		}
+		return char, nil
+	}
+}
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecodeInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		input    []byte
+		expected string
+	}{
+		{"UTF-8", "", []byte("café\t€"), "café\t€"},
+		{"UTF-8 BOM", "latin1", []byte("\xEF\xBB\xBFcafé"), "café"},
+		{"UTF-16LE BOM", "", []byte{0xFF, 0xFE, 'i', 0, 'd', 0, '\t', 0, 0xE9, 0, 0xAC, 0x20}, "id\té€"},
+		{"UTF-16BE BOM", "windows-1252", []byte{0xFE, 0xFF, 0, 'a', 0xD8, 0x3D, 0xDE, 0x00}, "a😀"},
+		{"UTF-16 without BOM", "utf-16", []byte{'a', 0, '\n', 0}, "a\n"},
+		{"UTF-16BE without BOM", "UTF-16BE", []byte{0, 'a', 0, 'b'}, "ab"},
+		{"Unpaired surrogate", "utf-16le", []byte{0x3D, 0xD8, 'a', 0}, "�a"},
+		{"Truncated code unit", "utf-16le", []byte{'a', 0, 'b'}, "a�"},
+		{"Latin-1", "ISO-8859-1", []byte("caf\xE9 \x80"), "café \u0080"},
+		{"Windows-1252", "cp1252", []byte("\x93caf\xE9\x94 \x80 \x81"), "“café” € \u0081"},
+		{"Empty", "utf-16", []byte{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := DecodeInput(bytes.NewReader(tt.input), tt.encoding)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			// one byte reads make sure characters split across reads are decoded
+			content, err := io.ReadAll(iotest.OneByteReader(reader))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(content) != tt.expected {
+				t.Errorf("DecodeInput() = %q, expected %q", content, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecodeInput_UnknownEncoding(t *testing.T) {
+	if _, err := DecodeInput(bytes.NewReader([]byte("a")), "ebcdic"); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+}
+
+func TestIsUTF8(t *testing.T) {
+	for _, encoding := range []string{"", "utf-8", "UTF8", " utf_8 "} {
+		if !IsUTF8(encoding) {
+			t.Errorf("IsUTF8(%q) should be true", encoding)
+		}
+	}
+	for _, encoding := range []string{"latin1", "utf-16"} {
+		if IsUTF8(encoding) {
+			t.Errorf("IsUTF8(%q) should be false", encoding)
+		}
+	}
+}